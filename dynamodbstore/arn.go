@@ -0,0 +1,122 @@
+package dynamodbstore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+var (
+	reAccountID = regexp.MustCompile(`^\d{12}$`)
+	reRegion    = regexp.MustCompile(`^[a-z0-9-]+$`)
+	reTableName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,255}$`)
+)
+
+// ARN holds the components of a dynamodb table, stream, or index arn e.g.
+//
+//	arn:aws:dynamodb:us-west-2:528688496454:table/orders
+//	arn:aws:dynamodb:us-west-2:528688496454:table/orders/stream/2017-03-14T04:49:34.930
+//	arn:aws-us-gov:dynamodb:us-gov-west-1:528688496454:table/orders/index/by-customer
+type ARN struct {
+	// Partition holds the aws partition; aws, aws-cn, or aws-us-gov
+	Partition string
+
+	// Region holds the region of the table e.g. us-west-2
+	Region string
+
+	// AccountID holds the id of the account that owns the table
+	AccountID string
+
+	// TableName holds the name of the table
+	TableName string
+
+	// StreamLabel holds the label of the stream; blank unless the arn refers to a stream
+	StreamLabel string
+
+	// IndexName holds the name of the index; blank unless the arn refers to an index
+	IndexName string
+}
+
+// ParseARN parses and validates a dynamodb table, stream, or index arn.  Invalid arns are reported
+// with the code ErrInvalidARN
+func ParseARN(s string) (ARN, error) {
+	invalid := func(reason string) (ARN, error) {
+		return ARN{}, eventsource.NewError(errInvalidEventSource, ErrInvalidARN, "%v - %v", s, reason)
+	}
+
+	segments := strings.SplitN(s, ":", 6)
+	if len(segments) != 6 || segments[0] != "arn" {
+		return invalid("expected arn:{partition}:dynamodb:{region}:{account}:{resource}")
+	}
+	if partition := segments[1]; partition != "aws" && !strings.HasPrefix(partition, "aws-") {
+		return invalid("unknown partition")
+	}
+	if segments[2] != "dynamodb" {
+		return invalid("not a dynamodb arn")
+	}
+	if !reRegion.MatchString(segments[3]) {
+		return invalid("invalid region")
+	}
+	if !reAccountID.MatchString(segments[4]) {
+		return invalid("invalid account id")
+	}
+
+	resource := strings.Split(segments[5], "/")
+	if resource[0] != "table" || len(resource) < 2 || !reTableName.MatchString(resource[1]) {
+		return invalid("invalid table name")
+	}
+
+	arn := ARN{
+		Partition: segments[1],
+		Region:    segments[3],
+		AccountID: segments[4],
+		TableName: resource[1],
+	}
+
+	switch {
+	case len(resource) == 2:
+	case len(resource) == 4 && resource[2] == "stream" && resource[3] != "":
+		arn.StreamLabel = resource[3]
+	case len(resource) == 4 && resource[2] == "index" && reTableName.MatchString(resource[3]):
+		arn.IndexName = resource[3]
+	default:
+		return invalid("expected table/{name}, table/{name}/stream/{label}, or table/{name}/index/{name}")
+	}
+
+	return arn, nil
+}
+
+// TableARN returns the arn of the table
+func (a ARN) TableARN() string {
+	return fmt.Sprintf("arn:%v:dynamodb:%v:%v:table/%v", a.Partition, a.Region, a.AccountID, a.TableName)
+}
+
+// StreamARN returns the arn of the stream; blank if the arn has no stream label
+func (a ARN) StreamARN() string {
+	if a.StreamLabel == "" {
+		return ""
+	}
+	return a.TableARN() + "/stream/" + a.StreamLabel
+}
+
+// IndexARN returns the arn of the index; blank if the arn has no index name
+func (a ARN) IndexARN() string {
+	if a.IndexName == "" {
+		return ""
+	}
+	return a.TableARN() + "/index/" + a.IndexName
+}
+
+// String returns the arn in its original form
+func (a ARN) String() string {
+	switch {
+	case a.StreamLabel != "":
+		return a.StreamARN()
+	case a.IndexName != "":
+		return a.IndexARN()
+	default:
+		return a.TableARN()
+	}
+}
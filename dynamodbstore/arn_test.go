@@ -0,0 +1,94 @@
+package dynamodbstore
+
+import (
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestParseARN(t *testing.T) {
+	testCases := map[string]struct {
+		Input    string
+		Expected ARN
+		Table    string
+		Stream   string
+		Index    string
+	}{
+		"table": {
+			Input:    "arn:aws:dynamodb:us-west-2:528688496454:table/orders",
+			Expected: ARN{Partition: "aws", Region: "us-west-2", AccountID: "528688496454", TableName: "orders"},
+			Table:    "arn:aws:dynamodb:us-west-2:528688496454:table/orders",
+		},
+		"stream": {
+			Input:    "arn:aws:dynamodb:us-west-2:528688496454:table/table-local-orgs/stream/2017-03-14T04:49:34.930",
+			Expected: ARN{Partition: "aws", Region: "us-west-2", AccountID: "528688496454", TableName: "table-local-orgs", StreamLabel: "2017-03-14T04:49:34.930"},
+			Table:    "arn:aws:dynamodb:us-west-2:528688496454:table/table-local-orgs",
+			Stream:   "arn:aws:dynamodb:us-west-2:528688496454:table/table-local-orgs/stream/2017-03-14T04:49:34.930",
+		},
+		"index": {
+			Input:    "arn:aws:dynamodb:us-west-2:528688496454:table/orders/index/by_customer",
+			Expected: ARN{Partition: "aws", Region: "us-west-2", AccountID: "528688496454", TableName: "orders", IndexName: "by_customer"},
+			Table:    "arn:aws:dynamodb:us-west-2:528688496454:table/orders",
+			Index:    "arn:aws:dynamodb:us-west-2:528688496454:table/orders/index/by_customer",
+		},
+		"govcloud": {
+			Input:    "arn:aws-us-gov:dynamodb:us-gov-west-1:528688496454:table/orders/stream/2019-01-01T00:00:00.000",
+			Expected: ARN{Partition: "aws-us-gov", Region: "us-gov-west-1", AccountID: "528688496454", TableName: "orders", StreamLabel: "2019-01-01T00:00:00.000"},
+			Table:    "arn:aws-us-gov:dynamodb:us-gov-west-1:528688496454:table/orders",
+			Stream:   "arn:aws-us-gov:dynamodb:us-gov-west-1:528688496454:table/orders/stream/2019-01-01T00:00:00.000",
+		},
+		"china": {
+			Input:    "arn:aws-cn:dynamodb:cn-north-1:528688496454:table/orders",
+			Expected: ARN{Partition: "aws-cn", Region: "cn-north-1", AccountID: "528688496454", TableName: "orders"},
+			Table:    "arn:aws-cn:dynamodb:cn-north-1:528688496454:table/orders",
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			arn, err := ParseARN(tc.Input)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got, want := arn, tc.Expected; got != want {
+				t.Fatalf("got %#v; want %#v", got, want)
+			}
+			if got, want := arn.String(), tc.Input; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := arn.TableARN(), tc.Table; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := arn.StreamARN(), tc.Stream; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := arn.IndexARN(), tc.Index; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseARN_Invalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"bogus",
+		"arn:aws:dynamodb:us-west-2:528688496454",
+		"arn:gcp:dynamodb:us-west-2:528688496454:table/orders",
+		"arn:aws:s3:us-west-2:528688496454:table/orders",
+		"arn:aws:dynamodb::528688496454:table/orders",
+		"arn:aws:dynamodb:us-west-2:5286:table/orders",
+		"arn:aws:dynamodb:us-west-2:528688496454:bucket/orders",
+		"arn:aws:dynamodb:us-west-2:528688496454:table/",
+		"arn:aws:dynamodb:us-west-2:528688496454:table/a",
+		"arn:aws:dynamodb:us-west-2:528688496454:table/orders/stream/",
+		"arn:aws:dynamodb:us-west-2:528688496454:table/orders/backup/abc",
+		"arn:aws:dynamodb:us-west-2:528688496454:table/orders/stream/abc/extra",
+	}
+
+	for _, input := range inputs {
+		if _, err := ParseARN(input); !eventsource.ErrHasCode(err, ErrInvalidARN) {
+			t.Fatalf("got %v; want %v for %q", err, ErrInvalidARN, input)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package dynamodbstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// committedBy returns false if the event within the item was committed after notAfter.  Events
+// without a commit time are treated as committed before any other.
+func committedBy(item map[string]*dynamodb.AttributeValue, version int, notAfter time.Time) bool {
+	if notAfter.IsZero() {
+		return true
+	}
+
+	committed, ok := committedAt(item, version)
+	return !ok || !committed.After(notAfter)
+}
+
+// firstCommitted returns the commit time of the first version the partition may hold; false if the
+// partition does not hold that version, as when truncated, or the event has no commit time.  Only the
+// commit time is read.
+func (s *Store) firstCommitted(ctx context.Context, aggregateID string, partition int) (time.Time, bool, error) {
+	first := partition * s.eventsPerItem
+	if first < 1 {
+		first = 1
+	}
+
+	out, err := s.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(s.tableName),
+		Key:                  s.makePartitionKey(aggregateID, partition),
+		ConsistentRead:       aws.Bool(true),
+		ProjectionExpression: aws.String("#committed"),
+		ExpressionAttributeNames: map[string]*string{
+			"#committed": aws.String(makeCommittedKey(first)),
+		},
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	committed, ok := committedAt(out.Item, first)
+	return committed, ok, nil
+}
+
+// LoadAsOf returns the events of the aggregate committed at or before t, the state of the aggregate
+// as of t.  Commit times are recorded by Save only once WithCommitTimes or WithRetention is enabled,
+// using the clock of the store, see WithClock, so are assumed to increase with version.  A binary search over the partition items finds the last that
+// may hold events committed by t and later partitions are never read.  Events saved before commit
+// times were recorded, or removed by TruncateBefore, are treated as committed before any other.  When
+// WithRetention is enabled, events hidden by the current retention policy remain hidden.
+func (s *Store) LoadAsOf(ctx context.Context, aggregateID string, t time.Time) (eventsource.History, error) {
+	latest, err := s.latestVersion(ctx, aggregateID)
+	if err != nil || latest == 0 {
+		return nil, err
+	}
+
+	// find the last partition whose first event was committed at or before t
+	to := -1
+	for lo, hi := selectPartition(1, s.eventsPerItem), selectPartition(latest, s.eventsPerItem); lo <= hi; {
+		mid := (lo + hi) / 2
+
+		committed, ok, err := s.firstCommitted(ctx, aggregateID, mid)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok || !committed.After(t) {
+			to = mid
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	if to < 0 {
+		return nil, nil
+	}
+
+	var (
+		fromVersion = 0
+		toVersion   = (to+1)*s.eventsPerItem - 1
+		options     = loadOptions{notAfter: t}
+	)
+	if s.retention {
+		if fromVersion, options, err = s.applyRetention(ctx, aggregateID, fromVersion, options); err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := s.load(ctx, aggregateID, fromVersion, toVersion, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.upcasters.Upcast(history...)
+}
@@ -0,0 +1,208 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// countingTable counts the partition items read individually, along with those read in full, and the
+// queries made
+type countingTable struct {
+	*memoryTable
+	gets    int
+	full    int
+	queries int
+}
+
+func (c *countingTable) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	c.gets++
+	if input.ProjectionExpression == nil {
+		c.full++
+	}
+	return c.memoryTable.GetItemWithContext(ctx, input, opts...)
+}
+
+func (c *countingTable) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	c.queries++
+	return c.memoryTable.QueryWithContext(ctx, input, opts...)
+}
+
+func TestStore_LoadAsOf(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		epoch = time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+		api   = &countingTable{memoryTable: &memoryTable{}}
+	)
+
+	// version n is committed n minutes after the epoch
+	for version := 1; version <= 40; version++ {
+		api.putEvents("abc", 4, epoch.Add(time.Duration(version)*time.Minute), version)
+	}
+
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(4))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	testCases := map[string]struct {
+		At       time.Time
+		Expected []int
+	}{
+		"before": {
+			At: epoch,
+		},
+		"first": {
+			At:       epoch.Add(time.Minute),
+			Expected: []int{1},
+		},
+		"within partition": {
+			At:       epoch.Add(22*time.Minute + time.Second),
+			Expected: versionRange(1, 22),
+		},
+		"partition boundary": {
+			At:       epoch.Add(24 * time.Minute),
+			Expected: versionRange(1, 24),
+		},
+		"after": {
+			At:       epoch.Add(time.Hour),
+			Expected: versionRange(1, 40),
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			api.gets = 0
+
+			history, err := store.LoadAsOf(ctx, "abc", tc.At)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got := versionsOf(history); !reflect.DeepEqual(got, tc.Expected) {
+				t.Fatalf("got %v; want %v", got, tc.Expected)
+			}
+
+			// 11 partitions are searched in at most 4 reads of a single attribute
+			if got, max := api.gets, 4; got > max {
+				t.Fatalf("got %v reads; want at most %v", got, max)
+			}
+			if got := api.full; got != 0 {
+				t.Fatalf("got %v full reads; want 0", got)
+			}
+		})
+	}
+}
+
+func TestStore_LoadAsOfSingleEventItems(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		epoch = time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+		api   = &countingTable{memoryTable: &memoryTable{}}
+	)
+
+	for version := 1; version <= 3; version++ {
+		api.putEvents("abc", 1, epoch.Add(time.Duration(version)*time.Minute), version)
+	}
+
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(1))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, err := store.LoadAsOf(ctx, "abc", epoch)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got := len(history); got != 0 {
+		t.Fatalf("got %v; want 0", got)
+	}
+
+	// only the latest version is queried; the events are never loaded
+	if got, want := api.queries, 1; got != want {
+		t.Fatalf("got %v queries; want %v", got, want)
+	}
+
+	history, err = store.LoadAsOf(ctx, "abc", epoch.Add(2*time.Minute))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versionsOf(history), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_LoadAsOfRetention(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		epoch = time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+		api   = &memoryTable{}
+	)
+
+	for version := 1; version <= 10; version++ {
+		api.putEvents("abc", 4, epoch.Add(time.Duration(version)*time.Minute), version)
+	}
+
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(4), WithRetention(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.SaveStreamMetadata(ctx, "abc", StreamMetadata{MaxCount: 5}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, err := store.LoadAsOf(ctx, "abc", epoch.Add(8*time.Minute))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versionsOf(history), []int{6, 7, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SaveClock(t *testing.T) {
+	now := time.Unix(0, 42)
+	store, err := New("blah", WithDynamoDB(&transactAPI{}), WithCommitTimes(true), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	items, err := store.makeEventWrites("abc", nil, eventsource.Record{Version: 1, Data: []byte("a")})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	update := items[0].Update
+	if got, want := *update.ExpressionAttributeValues[":committed"].N, strconv.FormatInt(now.UnixNano(), 10); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *update.ExpressionAttributeNames["#c_1"], makeCommittedKey(1); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SaveWithoutCommitTimes(t *testing.T) {
+	store, err := New("blah", WithDynamoDB(&transactAPI{}))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	items, err := store.makeEventWrites("abc", nil, eventsource.Record{Version: 1, Data: []byte("a")})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	update := items[0].Update
+	if _, ok := update.ExpressionAttributeValues[":committed"]; ok {
+		t.Fatalf("got commit time; want none")
+	}
+	if got, want := *update.UpdateExpression, "ADD #revision :one SET #_1 = :_1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
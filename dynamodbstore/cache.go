@@ -0,0 +1,311 @@
+package dynamodbstore
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// DefaultCacheAggregates is the default maximum number of aggregates held by a CachingStore
+	DefaultCacheAggregates = 1000
+
+	// DefaultCacheBytes is the default maximum number of bytes of event data held by a CachingStore
+	DefaultCacheBytes = 64 * 1024 * 1024
+
+	// recordOverhead approximates the memory used by a record in addition to its data
+	recordOverhead = 32
+)
+
+type cacheEntry struct {
+	aggregateID string
+	history     eventsource.History
+	size        int
+}
+
+func (e *cacheEntry) version() int {
+	if len(e.history) == 0 {
+		return 0
+	}
+	return e.history[len(e.history)-1].Version
+}
+
+// CachingStore is a read-through cache of aggregate histories that decorates an eventsource.Store.
+// Aggregates are evicted least recently used first once either bound is exceeded.  Loads of cached
+// aggregates fetch the most recent cached version along with the events saved after it, in a single
+// read, and discard the cached history if that version is no longer returned.
+type CachingStore struct {
+	store         eventsource.Store
+	upcasters     *Upcasters
+	revalidate    bool
+	maxAggregates int
+	maxBytes      int
+
+	mutex   sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// CacheOption represents a functional configuration of *CachingStore
+type CacheOption func(*CachingStore)
+
+// WithCacheLimits bounds the number of aggregates and the total bytes of event data cached; defaults to
+// DefaultCacheAggregates and DefaultCacheBytes
+func WithCacheLimits(maxAggregates, maxBytes int) CacheOption {
+	return func(c *CachingStore) {
+		c.maxAggregates = maxAggregates
+		c.maxBytes = maxBytes
+	}
+}
+
+// WithCacheUpcasters rewrites records saved or observed through the cache using upcasters, so they are
+// cached as the decorated store would load them.  Should be the upcasters passed to the decorated
+// store with WithUpcasters.
+func WithCacheUpcasters(upcasters *Upcasters) CacheOption {
+	return func(c *CachingStore) {
+		c.upcasters = upcasters
+	}
+}
+
+// WithCacheRevalidation additionally confirms the decorated store still returns the first cached
+// version on each load of a cached aggregate, at the cost of a second read.  Only needed when
+// aggregates are truncated or aged out by a retention policy while cached; deleted aggregates are
+// detected without it.
+func WithCacheRevalidation(enabled bool) CacheOption {
+	return func(c *CachingStore) {
+		c.revalidate = enabled
+	}
+}
+
+// NewCachingStore returns a CachingStore that decorates store, typically a *Store
+func NewCachingStore(store eventsource.Store, opts ...CacheOption) *CachingStore {
+	c := &CachingStore{
+		store:         store,
+		maxAggregates: DefaultCacheAggregates,
+		maxBytes:      DefaultCacheBytes,
+		lru:           list.New(),
+		entries:       map[string]*list.Element{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func historySize(history eventsource.History) int {
+	size := 0
+	for _, record := range history {
+		size += len(record.Data) + recordOverhead
+	}
+	return size
+}
+
+// cached returns a copy of the cached history of the aggregate
+func (c *CachingStore) cached(aggregateID string) eventsource.History {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[aggregateID]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(element)
+
+	history := element.Value.(*cacheEntry).history
+	return append(make(eventsource.History, 0, len(history)), history...)
+}
+
+// put caches the complete history of the aggregate unless a more recent history is already cached
+func (c *CachingStore) put(aggregateID string, history eventsource.History) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &cacheEntry{
+		aggregateID: aggregateID,
+		history:     history,
+		size:        historySize(history),
+	}
+
+	if element, ok := c.entries[aggregateID]; ok {
+		existing := element.Value.(*cacheEntry)
+		if existing.version() > entry.version() {
+			return
+		}
+		c.remove(element)
+	}
+
+	if entry.size > c.maxBytes {
+		return
+	}
+
+	c.entries[aggregateID] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.lru.Len() > c.maxAggregates || c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove must be called with the mutex held
+func (c *CachingStore) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.aggregateID)
+	c.size -= entry.size
+}
+
+// append extends the cached history of the aggregate with records that immediately follow it.  The
+// aggregate is evicted if the records leave a gap and ignored if it is not cached.
+func (c *CachingStore) append(aggregateID string, records ...eventsource.Record) {
+	c.mutex.Lock()
+	element, ok := c.entries[aggregateID]
+	if !ok {
+		c.mutex.Unlock()
+		return
+	}
+
+	entry := element.Value.(*cacheEntry)
+	version := entry.version()
+	for len(records) > 0 && records[0].Version <= version {
+		records = records[1:]
+	}
+	if len(records) == 0 {
+		c.mutex.Unlock()
+		return
+	}
+	if records[0].Version != version+1 {
+		c.remove(element)
+		c.mutex.Unlock()
+		return
+	}
+
+	history := append(append(eventsource.History{}, entry.history...), records...)
+	c.mutex.Unlock()
+
+	c.put(aggregateID, history)
+}
+
+// Invalidate evicts the aggregate from the cache
+func (c *CachingStore) Invalidate(aggregateID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[aggregateID]; ok {
+		c.remove(element)
+	}
+}
+
+// returns reports whether the decorated store still returns the version of the aggregate, as it
+// will not once the aggregate has been truncated, deleted or aged out by its retention policy
+func (c *CachingStore) returns(ctx context.Context, aggregateID string, version int) (bool, error) {
+	current, err := c.store.Load(ctx, aggregateID, version, version)
+	if err != nil {
+		return false, err
+	}
+
+	return len(current) > 0 && current[0].Version == version, nil
+}
+
+// refresh extends the cached history with the events saved since it was cached.  The most recent
+// cached version is read along with them and, if it is no longer returned, the complete history is
+// read again instead.
+func (c *CachingStore) refresh(ctx context.Context, aggregateID string, history eventsource.History) (eventsource.History, error) {
+	if len(history) > 0 && c.revalidate {
+		ok, err := c.returns(ctx, aggregateID, history[0].Version)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			c.Invalidate(aggregateID)
+			history = nil
+		}
+	}
+
+	if len(history) == 0 {
+		return c.store.Load(ctx, aggregateID, 1, 0)
+	}
+
+	version := history[len(history)-1].Version
+	fresh, err := c.store.Load(ctx, aggregateID, version, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(fresh) == 0 || fresh[0].Version != version {
+		c.Invalidate(aggregateID)
+		return c.store.Load(ctx, aggregateID, 1, 0)
+	}
+
+	return append(history, fresh[1:]...), nil
+}
+
+// Load implements eventsource.Store.  The complete history of the aggregate is cached regardless of
+// the versions requested.  The cached history is discarded if the decorated store no longer returns
+// its most recent version or, with WithCacheRevalidation, its first version.
+func (c *CachingStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	history, err := c.refresh(ctx, aggregateID, c.cached(aggregateID))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history) > 0 {
+		c.put(aggregateID, history)
+	}
+
+	selected := make(eventsource.History, 0, len(history))
+	for _, record := range history {
+		if record.Version < fromVersion {
+			continue
+		}
+		if toVersion > 0 && record.Version > toVersion {
+			break
+		}
+		selected = append(selected, record)
+	}
+
+	return selected, nil
+}
+
+// upcast rewrites records saved or observed through the cache the same way the decorated store
+// rewrites records it loads, see WithCacheUpcasters; false if the records could not be upcast
+func (c *CachingStore) upcast(records []eventsource.Record) ([]eventsource.Record, bool) {
+	upcasted, err := c.upcasters.Upcast(records...)
+	return upcasted, err == nil
+}
+
+// Save implements eventsource.Store; once saved, the records are appended to the cached history
+func (c *CachingStore) Save(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
+	if err := c.store.Save(ctx, aggregateID, records...); err != nil {
+		return err
+	}
+
+	sorted := append(make(eventsource.History, 0, len(records)), records...)
+	sort.Sort(sorted)
+
+	upcasted, ok := c.upcast(sorted)
+	if !ok {
+		c.Invalidate(aggregateID)
+		return nil
+	}
+
+	c.append(aggregateID, upcasted...)
+	return nil
+}
+
+// Observe keeps the cache current from the dynamodb stream of the table.  Observe has the same
+// signature as StreamHandler and subscribe.Handler callbacks so it may be passed to either directly.
+// Records that leave a gap in the cached history evict the aggregate.
+func (c *CachingStore) Observe(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+	upcasted, ok := c.upcast(records)
+	if !ok {
+		c.Invalidate(aggregateID)
+		return nil
+	}
+
+	c.append(aggregateID, upcasted...)
+	return nil
+}
@@ -0,0 +1,266 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// loadRecorder records the fromVersion of each Load made against the decorated store
+type loadRecorder struct {
+	versionedStore
+	loads []int
+}
+
+func (l *loadRecorder) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	l.loads = append(l.loads, fromVersion)
+	return l.versionedStore.Load(ctx, aggregateID, fromVersion, toVersion)
+}
+
+func makeHistory(from, to int) eventsource.History {
+	var history eventsource.History
+	for version := from; version <= to; version++ {
+		history = append(history, eventsource.Record{Version: version, Data: []byte{byte(version)}})
+	}
+	return history
+}
+
+func TestCachingStore_Load(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &loadRecorder{versionedStore: versionedStore{records: recordStore{"abc": makeHistory(1, 3)}}}
+		cache = NewCachingStore(store)
+	)
+
+	history, err := cache.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := makeHistory(1, 3); !reflect.DeepEqual(history, want) {
+		t.Fatalf("got %v; want %v", history, want)
+	}
+
+	// saved by another writer
+	store.records["abc"] = append(store.records["abc"], makeHistory(4, 4)...)
+
+	history, err = cache.Load(ctx, "abc", 2, 3)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := makeHistory(2, 3); !reflect.DeepEqual(history, want) {
+		t.Fatalf("got %v; want %v", history, want)
+	}
+
+	history, err = cache.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := makeHistory(1, 4); !reflect.DeepEqual(history, want) {
+		t.Fatalf("got %v; want %v", history, want)
+	}
+
+	// each cached load reads from the most recent cached version
+	if got, want := store.loads, []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestCachingStore_Save(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &loadRecorder{versionedStore: versionedStore{records: recordStore{"abc": makeHistory(1, 2)}}}
+		cache = NewCachingStore(store)
+	)
+
+	if _, err := cache.Load(ctx, "abc", 0, 0); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := cache.Save(ctx, "abc", makeHistory(3, 4)...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, err := cache.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := makeHistory(1, 4); !reflect.DeepEqual(history, want) {
+		t.Fatalf("got %v; want %v", history, want)
+	}
+	if got, want := store.loads, []int{1, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// failed saves leave the cache untouched
+	if err := cache.Save(ctx, "abc", makeHistory(3, 3)...); err == nil {
+		t.Fatalf("got nil; want conflict")
+	}
+	if got := cache.cached("abc"); !reflect.DeepEqual(got, makeHistory(1, 4)) {
+		t.Fatalf("got %v; want %v", got, makeHistory(1, 4))
+	}
+}
+
+func TestCachingStore_Revalidation(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &loadRecorder{versionedStore: versionedStore{records: recordStore{"abc": makeHistory(1, 4)}}}
+		cache = NewCachingStore(store, WithCacheRevalidation(true))
+	)
+
+	if _, err := cache.Load(ctx, "abc", 0, 0); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// truncated, or aged out by retention, since cached
+	store.records["abc"] = makeHistory(3, 4)
+
+	history, err := cache.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := makeHistory(3, 4); !reflect.DeepEqual(history, want) {
+		t.Fatalf("got %v; want %v", history, want)
+	}
+
+	// revalidated from the first cached version before reading from the most recent
+	history, err = cache.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := makeHistory(3, 4); !reflect.DeepEqual(history, want) {
+		t.Fatalf("got %v; want %v", history, want)
+	}
+	if got, want := store.loads, []int{1, 1, 1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestCachingStore_Deleted(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &versionedStore{records: recordStore{"abc": makeHistory(1, 4)}}
+		cache = NewCachingStore(store)
+	)
+
+	if _, err := cache.Load(ctx, "abc", 0, 0); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// deleted since cached
+	delete(store.records, "abc")
+
+	history, err := cache.Load(ctx, "abc", 0, 0)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("got %v; want none", history)
+	}
+	if got := cache.cached("abc"); got != nil {
+		t.Fatalf("got %v; want nil", got)
+	}
+}
+
+func TestCachingStore_Upcasters(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		store     = &versionedStore{records: recordStore{"abc": {{Version: 1, Data: []byte("created:2:a")}}}}
+		upcasters = NewUpcasters(testSchemaReader,
+			Upcaster{EventType: "created", SchemaVersion: 1, Chain: []UpcastFunc{testUpcast('2')}},
+		)
+		cache = NewCachingStore(store, WithCacheUpcasters(upcasters))
+	)
+
+	if _, err := cache.Load(ctx, "abc", 0, 0); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := cache.Save(ctx, "abc", eventsource.Record{Version: 2, Data: []byte("created:1:b")}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := eventsource.History{
+		{Version: 1, Data: []byte("created:2:a")},
+		{Version: 2, Data: []byte("created:2:b+")},
+	}
+	if got := cache.cached("abc"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestCachingStore_Observe(t *testing.T) {
+	ctx := context.Background()
+	cache := NewCachingStore(&versionedStore{records: recordStore{"abc": makeHistory(1, 2)}})
+	if _, err := cache.Load(ctx, "abc", 0, 0); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// overlapping records are appended once
+	if err := cache.Observe(ctx, "abc", makeHistory(2, 3)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := cache.cached("abc"), makeHistory(1, 3); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// a gap evicts the aggregate
+	if err := cache.Observe(ctx, "abc", makeHistory(5, 5)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got := cache.cached("abc"); got != nil {
+		t.Fatalf("got %v; want nil", got)
+	}
+
+	// aggregates that are not cached are ignored
+	if err := cache.Observe(ctx, "def", makeHistory(1, 1)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got := cache.cached("def"); got != nil {
+		t.Fatalf("got %v; want nil", got)
+	}
+}
+
+func TestCachingStore_Limits(t *testing.T) {
+	ctx := context.Background()
+	store := &versionedStore{records: recordStore{
+		"a": makeHistory(1, 1),
+		"b": makeHistory(1, 1),
+		"c": makeHistory(1, 1),
+		"d": makeHistory(1, 4),
+	}}
+
+	t.Run("aggregates", func(t *testing.T) {
+		cache := NewCachingStore(store, WithCacheLimits(2, DefaultCacheBytes))
+		for _, id := range []string{"a", "b", "a", "c"} {
+			if _, err := cache.Load(ctx, id, 0, 0); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		}
+
+		// b was least recently used
+		for id, want := range map[string]bool{"a": true, "b": false, "c": true} {
+			if got := cache.cached(id) != nil; got != want {
+				t.Fatalf("%v: got %v; want %v", id, got, want)
+			}
+		}
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		cache := NewCachingStore(store, WithCacheLimits(10, 3*(1+recordOverhead)))
+		for _, id := range []string{"a", "b", "c", "d"} {
+			if _, err := cache.Load(ctx, id, 0, 0); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		}
+
+		// d alone exceeds the bound and is never cached
+		for id, want := range map[string]bool{"a": true, "b": true, "c": true, "d": false} {
+			if got := cache.cached(id) != nil; got != want {
+				t.Fatalf("%v: got %v; want %v", id, got, want)
+			}
+		}
+		if got, want := cache.size, 3*(1+recordOverhead); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
@@ -3,7 +3,6 @@ package dynamodbstore
 import (
 	"errors"
 	"sort"
-	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/eventsource-ecosystem/eventsource"
@@ -62,10 +61,10 @@ var (
 // TableName extracts a table name from a dynamodb event source arn
 // arn:aws:dynamodb:us-west-2:528688496454:table/table-local-orgs/stream/2017-03-14T04:49:34.930
 func TableName(eventSourceArn string) (string, error) {
-	segments := strings.Split(eventSourceArn, "/")
-	if len(segments) < 2 {
-		return "", errInvalidEventSource
+	arn, err := ParseARN(eventSourceArn)
+	if err != nil {
+		return "", err
 	}
 
-	return segments[1], nil
+	return arn.TableName, nil
 }
@@ -0,0 +1,98 @@
+package dynamodbstore
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+var (
+	errMissingHashKey  = errors.New("stream record is missing the hash key")
+	errMissingRangeKey = errors.New("stream record is missing the range key")
+)
+
+// ChangeSet holds the events introduced by a single dynamodb stream record along with the metadata
+// of the record itself
+type ChangeSet struct {
+	// AggregateID holds the hash key of the item that changed
+	AggregateID string
+
+	// Partition holds the range key of the item that changed
+	Partition int
+
+	// EventName holds the kind of change; one of INSERT, MODIFY, or REMOVE
+	EventName events.DynamoDBOperationType
+
+	// SequenceNumber holds the position of the record within its stream shard
+	SequenceNumber string
+
+	// ApproximateCreationTime holds the approximate time the change was made
+	ApproximateCreationTime time.Time
+
+	// Records holds the new events, sorted by version; empty if the change introduced none
+	Records []eventsource.Record
+}
+
+// ParseEventRecord returns the ChangeSet of a dynamodb stream record delivered to lambda.  Returns an
+// *IntegrityError if the record modifies or removes previously committed events, along with the
+// ChangeSet holding any events the record also appended.
+func ParseEventRecord(record events.DynamoDBEventRecord) (ChangeSet, error) {
+	return parseChange(events.DynamoDBOperationType(record.EventName), record.Change)
+}
+
+// ParseStreamRecord returns the ChangeSet of a record read using the dynamodb streams api.  Returns an
+// *IntegrityError if the record modifies or removes previously committed events, along with the
+// ChangeSet holding any events the record also appended.
+func ParseStreamRecord(record *dynamodbstreams.Record) (ChangeSet, error) {
+	var eventName events.DynamoDBOperationType
+	if record.EventName != nil {
+		eventName = events.DynamoDBOperationType(*record.EventName)
+	}
+
+	var change events.DynamoDBStreamRecord
+	if record.Dynamodb != nil {
+		change = convertStreamRecord(record.Dynamodb)
+	}
+
+	return parseChange(eventName, change)
+}
+
+func parseChange(eventName events.DynamoDBOperationType, change events.DynamoDBStreamRecord) (ChangeSet, error) {
+	hashKey, ok := change.Keys[HashKey]
+	if !ok || hashKey.DataType() != events.DataTypeString {
+		return ChangeSet{}, errMissingHashKey
+	}
+
+	rangeKey, ok := change.Keys[RangeKey]
+	if !ok || rangeKey.DataType() != events.DataTypeNumber {
+		return ChangeSet{}, errMissingRangeKey
+	}
+	partition, err := strconv.Atoi(rangeKey.Number())
+	if err != nil {
+		return ChangeSet{}, errMissingRangeKey
+	}
+
+	aggregateID := hashKey.String()
+	violation := checkIntegrity(eventName, aggregateID, partition, change)
+	if _, ok := violation.(*IntegrityError); violation != nil && !ok {
+		return ChangeSet{}, violation
+	}
+
+	records, err := Changes(change)
+	if err != nil {
+		return ChangeSet{}, err
+	}
+
+	return ChangeSet{
+		AggregateID:             aggregateID,
+		Partition:               partition,
+		EventName:               eventName,
+		SequenceNumber:          change.SequenceNumber,
+		ApproximateCreationTime: change.ApproximateCreationDateTime.Time,
+		Records:                 records,
+	}, violation
+}
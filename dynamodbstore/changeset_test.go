@@ -0,0 +1,126 @@
+package dynamodbstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestParseChangeSet(t *testing.T) {
+	now := time.Unix(1560000000, 0)
+	want := ChangeSet{
+		AggregateID:             "abc",
+		Partition:               1,
+		EventName:               events.DynamoDBOperationTypeModify,
+		SequenceNumber:          "123",
+		ApproximateCreationTime: now,
+		Records: []eventsource.Record{
+			{Version: 101, Data: []byte("b")},
+		},
+	}
+
+	t.Run("event record", func(t *testing.T) {
+		got, err := ParseEventRecord(events.DynamoDBEventRecord{
+			EventName: "MODIFY",
+			Change: events.DynamoDBStreamRecord{
+				ApproximateCreationDateTime: events.SecondsEpochTime{Time: now},
+				SequenceNumber:              "123",
+				Keys: map[string]events.DynamoDBAttributeValue{
+					HashKey:  events.NewStringAttribute("abc"),
+					RangeKey: events.NewNumberAttribute("1"),
+				},
+				OldImage: map[string]events.DynamoDBAttributeValue{
+					"_100": events.NewBinaryAttribute([]byte("a")),
+				},
+				NewImage: map[string]events.DynamoDBAttributeValue{
+					"_100": events.NewBinaryAttribute([]byte("a")),
+					"_101": events.NewBinaryAttribute([]byte("b")),
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v; want %#v", got, want)
+		}
+	})
+
+	t.Run("violation", func(t *testing.T) {
+		got, err := ParseEventRecord(events.DynamoDBEventRecord{
+			EventName: "MODIFY",
+			Change: events.DynamoDBStreamRecord{
+				ApproximateCreationDateTime: events.SecondsEpochTime{Time: now},
+				SequenceNumber:              "123",
+				Keys: map[string]events.DynamoDBAttributeValue{
+					HashKey:  events.NewStringAttribute("abc"),
+					RangeKey: events.NewNumberAttribute("1"),
+				},
+				OldImage: map[string]events.DynamoDBAttributeValue{
+					"_100": events.NewBinaryAttribute([]byte("a")),
+				},
+				NewImage: map[string]events.DynamoDBAttributeValue{
+					"_100": events.NewBinaryAttribute([]byte("altered")),
+					"_101": events.NewBinaryAttribute([]byte("b")),
+				},
+			},
+		})
+		if _, ok := err.(*IntegrityError); !ok {
+			t.Fatalf("got %v; want *IntegrityError", err)
+		}
+		// events appended alongside the violation are still returned
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v; want %#v", got, want)
+		}
+	})
+
+	t.Run("stream record", func(t *testing.T) {
+		got, err := ParseStreamRecord(&dynamodbstreams.Record{
+			EventName: aws.String(dynamodbstreams.OperationTypeModify),
+			Dynamodb: &dynamodbstreams.StreamRecord{
+				ApproximateCreationDateTime: aws.Time(now),
+				SequenceNumber:              aws.String("123"),
+				Keys: map[string]*dynamodb.AttributeValue{
+					HashKey:  {S: aws.String("abc")},
+					RangeKey: {N: aws.String("1")},
+				},
+				OldImage: map[string]*dynamodb.AttributeValue{
+					"_100": {B: []byte("a")},
+				},
+				NewImage: map[string]*dynamodb.AttributeValue{
+					"_100": {B: []byte("a")},
+					"_101": {B: []byte("b")},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %#v; want %#v", got, want)
+		}
+	})
+
+	t.Run("missing keys", func(t *testing.T) {
+		if _, err := ParseEventRecord(events.DynamoDBEventRecord{}); err != errMissingHashKey {
+			t.Fatalf("got %v; want %v", err, errMissingHashKey)
+		}
+
+		_, err := ParseEventRecord(events.DynamoDBEventRecord{
+			Change: events.DynamoDBStreamRecord{
+				Keys: map[string]events.DynamoDBAttributeValue{
+					HashKey: events.NewStringAttribute("abc"),
+				},
+			},
+		})
+		if err != errMissingRangeKey {
+			t.Fatalf("got %v; want %v", err, errMissingRangeKey)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package dynamodbstore
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+)
+
+// convertAttributeValue converts an sdk attribute value into its lambda equivalent so that it may be
+// passed to Changes
+func convertAttributeValue(av *dynamodb.AttributeValue) events.DynamoDBAttributeValue {
+	switch {
+	case av == nil:
+		return events.NewNullAttribute()
+	case av.B != nil:
+		return events.NewBinaryAttribute(av.B)
+	case av.S != nil:
+		return events.NewStringAttribute(*av.S)
+	case av.N != nil:
+		return events.NewNumberAttribute(*av.N)
+	case av.BOOL != nil:
+		return events.NewBooleanAttribute(*av.BOOL)
+	case av.BS != nil:
+		return events.NewBinarySetAttribute(av.BS)
+	case av.SS != nil:
+		ss := make([]string, 0, len(av.SS))
+		for _, v := range av.SS {
+			ss = append(ss, *v)
+		}
+		return events.NewStringSetAttribute(ss)
+	case av.NS != nil:
+		ns := make([]string, 0, len(av.NS))
+		for _, v := range av.NS {
+			ns = append(ns, *v)
+		}
+		return events.NewNumberSetAttribute(ns)
+	case av.M != nil:
+		return events.NewMapAttribute(convertImage(av.M))
+	case av.L != nil:
+		l := make([]events.DynamoDBAttributeValue, 0, len(av.L))
+		for _, v := range av.L {
+			l = append(l, convertAttributeValue(v))
+		}
+		return events.NewListAttribute(l)
+	default:
+		return events.NewNullAttribute()
+	}
+}
+
+func convertImage(image map[string]*dynamodb.AttributeValue) map[string]events.DynamoDBAttributeValue {
+	if image == nil {
+		return nil
+	}
+
+	m := make(map[string]events.DynamoDBAttributeValue, len(image))
+	for k, v := range image {
+		m[k] = convertAttributeValue(v)
+	}
+	return m
+}
+
+// convertStreamRecord converts a record read via the dynamodb streams api into its lambda equivalent
+func convertStreamRecord(record *dynamodbstreams.StreamRecord) events.DynamoDBStreamRecord {
+	change := events.DynamoDBStreamRecord{
+		Keys:     convertImage(record.Keys),
+		NewImage: convertImage(record.NewImage),
+		OldImage: convertImage(record.OldImage),
+	}
+	if record.ApproximateCreationDateTime != nil {
+		change.ApproximateCreationDateTime = events.SecondsEpochTime{Time: *record.ApproximateCreationDateTime}
+	}
+	if record.SequenceNumber != nil {
+		change.SequenceNumber = *record.SequenceNumber
+	}
+	if record.SizeBytes != nil {
+		change.SizeBytes = *record.SizeBytes
+	}
+	if record.StreamViewType != nil {
+		change.StreamViewType = *record.StreamViewType
+	}
+	return change
+}
@@ -0,0 +1,353 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// deletedAttribute marks the head item of an aggregate that no longer accepts events
+	deletedAttribute = "deleted"
+
+	// truncatedAttribute holds the version before which the events of the aggregate, or of the
+	// partition item, were removed
+	truncatedAttribute = "truncated"
+)
+
+// addMarkerConditions extends the condition of the head update so the save is rejected once the
+// aggregate has been deleted or when first precedes the truncation point of the aggregate
+func addMarkerConditions(update *dynamodb.Update, first int) {
+	update.ExpressionAttributeNames["#deleted"] = aws.String(deletedAttribute)
+	update.ExpressionAttributeNames["#truncated"] = aws.String(truncatedAttribute)
+	update.ExpressionAttributeValues[":first"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(first))}
+
+	expr := "attribute_not_exists(#deleted) AND (attribute_not_exists(#truncated) OR #truncated <= :first)"
+	if update.ConditionExpression != nil {
+		expr = *update.ConditionExpression + " AND " + expr
+	}
+	update.ConditionExpression = aws.String(expr)
+}
+
+// checkDeleted returns ErrAggregateDeleted if the head item of the aggregate carries the deletion
+// marker
+func (s *Store) checkDeleted(ctx context.Context, aggregateID string) error {
+	out, err := s.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(s.tableName),
+		Key:                  makeHeadKey(s.hashKey, s.rangeKey, aggregateID),
+		ConsistentRead:       aws.Bool(true),
+		ProjectionExpression: aws.String("#deleted"),
+		ExpressionAttributeNames: map[string]*string{
+			"#deleted": aws.String(deletedAttribute),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, ok := out.Item[deletedAttribute]; ok {
+		return eventsource.NewError(nil, ErrAggregateDeleted, "aggregate, %v, has been deleted", aggregateID)
+	}
+
+	return nil
+}
+
+// checkDeletion returns ErrDeletionDisabled unless saves check the deletion and truncation markers
+func (s *Store) checkDeletion() error {
+	if !s.deletion {
+		return eventsource.NewError(nil, ErrDeletionDisabled, "aggregates may only be deleted or truncated by a store created WithDeletion")
+	}
+	return nil
+}
+
+func (s *Store) makePartitionKey(aggregateID string, partition int) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		s.hashKey:  {S: aws.String(aggregateID)},
+		s.rangeKey: {N: aws.String(strconv.Itoa(partition))},
+	}
+}
+
+// Delete closes the aggregate; Save rejects further records with ErrAggregateDeleted.  A soft delete
+// retains the history of the aggregate while a hard delete removes every event along with the
+// snapshot, stream metadata, reservations, idempotency keys and outbox entries of the aggregate.
+// Idempotency keys and outbox entries are keyed individually so a hard delete scans the table for
+// them.
+//
+// The deletion marker is recorded in the head item, which every save conditions on, and is retained
+// by a hard delete so the aggregate may not be recreated.  Requires WithDeletion.
+func (s *Store) Delete(ctx context.Context, aggregateID string, soft bool) error {
+	if err := s.checkDeletion(); err != nil {
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(s.tableName),
+		Key:              makeHeadKey(s.hashKey, s.rangeKey, aggregateID),
+		UpdateExpression: aws.String("SET #deleted = :deleted"),
+		ExpressionAttributeNames: map[string]*string{
+			"#deleted": aws.String(deletedAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":deleted": {BOOL: aws.Bool(true)},
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	}
+	s.dump(input)
+
+	out, err := s.api.UpdateItemWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	if soft {
+		return nil
+	}
+
+	latest, err := s.latestVersion(ctx, aggregateID)
+	if err != nil {
+		return err
+	}
+	if latest > 0 {
+		if err := s.removePartitions(ctx, aggregateID, selectPartition(latest, s.eventsPerItem), latest+1); err != nil {
+			return err
+		}
+	}
+
+	if err := s.releaseReservations(ctx, aggregateID, out.Attributes); err != nil {
+		return err
+	}
+	if err := s.deleteSnapshot(ctx, aggregateID); err != nil {
+		return err
+	}
+	if err := s.removeKeyedItems(ctx, aggregateID); err != nil {
+		return err
+	}
+
+	return s.SaveStreamMetadata(ctx, aggregateID, StreamMetadata{})
+}
+
+// TruncateBefore permanently removes the events of the aggregate before version.  A snapshot as of
+// version-1 or later must first be saved with SaveSnapshot, otherwise ErrSnapshotRequired is returned
+// as the aggregate could no longer be loaded.  Partition items holding only earlier events are
+// deleted and the earlier events are removed from the partition item holding version.
+//
+// The truncation point is recorded in the head item, which every save conditions on, so Save rejects
+// records before version.  Requires WithDeletion.
+func (s *Store) TruncateBefore(ctx context.Context, aggregateID string, version int) error {
+	if err := s.checkDeletion(); err != nil {
+		return err
+	}
+	if version <= 1 {
+		return nil
+	}
+
+	snapshot, ok, err := s.LoadSnapshot(ctx, aggregateID)
+	if err != nil {
+		return err
+	}
+	if !ok || snapshot.Version < version-1 {
+		return eventsource.NewError(nil, ErrSnapshotRequired, "unable to truncate aggregate, %v, before version %v; snapshot is as of version %v", aggregateID, version, snapshot.Version)
+	}
+
+	for _, key := range []map[string]*dynamodb.AttributeValue{
+		makeHeadKey(s.hashKey, s.rangeKey, aggregateID),
+		s.makePartitionKey(aggregateID, selectPartition(version, s.eventsPerItem)),
+	} {
+		if err := s.truncateItem(ctx, key, version); err != nil {
+			return err
+		}
+	}
+
+	return s.removePartitions(ctx, aggregateID, selectPartition(version, s.eventsPerItem)-1, version)
+}
+
+// removeKeyedItems deletes the idempotency and outbox items of the aggregate.  Each is keyed by the
+// aggregate along with its own id, so the items are found by scanning for those that name the
+// aggregate.
+func (s *Store) removeKeyedItems(ctx context.Context, aggregateID string) error {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(s.tableName),
+		ConsistentRead:       aws.Bool(true),
+		FilterExpression:     aws.String("(begins_with(#key, :idempotency) OR begins_with(#key, :outbox)) AND #aggregate = :aggregate"),
+		ProjectionExpression: aws.String("#key, #partition"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":       aws.String(s.hashKey),
+			"#partition": aws.String(s.rangeKey),
+			"#aggregate": aws.String(aggregateAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":idempotency": {S: aws.String(idempotencyPrefix + aggregateID + "/")},
+			":outbox":      {S: aws.String(outboxPrefix + aggregateID + "/")},
+			":aggregate":   {S: aws.String(aggregateID)},
+		},
+	}
+
+	for {
+		out, err := s.api.ScanWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range out.Items {
+			_, err := s.api.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(s.tableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					s.hashKey:  item[s.hashKey],
+					s.rangeKey: item[s.rangeKey],
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// truncateItem records the truncation point in the item and removes any events it holds before
+// version.  The item is left untouched if previously truncated at or beyond version, or if it is a
+// partition item that does not exist.
+func (s *Store) truncateItem(ctx context.Context, key map[string]*dynamodb.AttributeValue, version int) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(s.tableName),
+		Key:                 key,
+		ConditionExpression: aws.String("attribute_not_exists(#truncated) OR #truncated < :version"),
+		ExpressionAttributeNames: map[string]*string{
+			"#truncated": aws.String(truncatedAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(version))},
+		},
+	}
+
+	var refs []string
+	if partition, _ := strconv.Atoi(*key[s.rangeKey].N); partition != headPartition {
+		// only the head may be created to hold the truncation point
+		input.ConditionExpression = aws.String("attribute_exists(#key) AND (" + *input.ConditionExpression + ")")
+		input.ExpressionAttributeNames["#key"] = aws.String(s.hashKey)
+
+		for v := partition * s.eventsPerItem; v < version; v++ {
+			if v == 0 {
+				continue
+			}
+			eventRef, committedRef := "#"+makeKey(v), "#c"+makeKey(v)
+			input.ExpressionAttributeNames[eventRef] = aws.String(makeKey(v))
+			input.ExpressionAttributeNames[committedRef] = aws.String(makeCommittedKey(v))
+			refs = append(refs, eventRef, committedRef)
+		}
+	}
+
+	expr := "SET #truncated = :version"
+	if len(refs) > 0 {
+		expr += " REMOVE " + strings.Join(refs, ", ")
+	}
+	input.UpdateExpression = aws.String(expr)
+
+	if err := checkUpdateLimits(makeUpdate(input)); err != nil {
+		return err
+	}
+	s.dump(input)
+
+	if _, err := s.api.UpdateItemWithContext(ctx, input); err != nil && !IsConditionalCheckFailed(err) {
+		return err
+	}
+
+	return nil
+}
+
+// removePartitions expires and deletes the partition items of the aggregate from 0 through to.  The
+// items must hold only events before fromVersion.
+func (s *Store) removePartitions(ctx context.Context, aggregateID string, to, fromVersion int) error {
+	if to < 0 {
+		return nil
+	}
+
+	input, err := makeQueryInput(s.tableName, s.hashKey, s.rangeKey, aggregateID, 0, to)
+	if err != nil {
+		return err
+	}
+	input.Select = aws.String(dynamodb.SelectSpecificAttributes)
+	input.ProjectionExpression = aws.String("#key, #partition, #retainFrom")
+	input.ExpressionAttributeNames["#retainFrom"] = aws.String(retainFromAttribute)
+
+	for {
+		out, err := s.api.QueryWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range out.Items {
+			if err := s.expireItem(ctx, item, fromVersion, time.Time{}, false); err != nil {
+				return err
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// expireItem marks the item with ExpiresAttribute along with the retention window its events fell
+// outside of, events before fromVersion or committed before notBefore, and deletes it unless keep is
+// set in which case deletion is left to dynamodb TTL.  The integrity check exempts the removal only
+// if every event of the item falls outside the recorded window.
+func (s *Store) expireItem(ctx context.Context, item map[string]*dynamodb.AttributeValue, fromVersion int, notBefore time.Time, keep bool) error {
+	key := map[string]*dynamodb.AttributeValue{
+		s.hashKey:  item[s.hashKey],
+		s.rangeKey: item[s.rangeKey],
+	}
+
+	if _, ok := item[retainFromAttribute]; !ok {
+		input := &dynamodb.UpdateItemInput{
+			TableName:           aws.String(s.tableName),
+			Key:                 key,
+			ConditionExpression: aws.String("attribute_exists(#key)"),
+			UpdateExpression:    aws.String("SET #expires = :expires, #retainFrom = :retainFrom"),
+			ExpressionAttributeNames: map[string]*string{
+				"#key":        aws.String(s.hashKey),
+				"#expires":    aws.String(ExpiresAttribute),
+				"#retainFrom": aws.String(retainFromAttribute),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":expires":    {N: aws.String(strconv.FormatInt(s.now().Unix(), 10))},
+				":retainFrom": {N: aws.String(strconv.Itoa(fromVersion))},
+			},
+		}
+		if !notBefore.IsZero() {
+			input.UpdateExpression = aws.String(*input.UpdateExpression + ", #retainSince = :retainSince")
+			input.ExpressionAttributeNames["#retainSince"] = aws.String(retainSinceAttribute)
+			input.ExpressionAttributeValues[":retainSince"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(notBefore.UnixNano(), 10))}
+		}
+		s.dump(input)
+
+		if _, err := s.api.UpdateItemWithContext(ctx, input); err != nil {
+			if IsConditionalCheckFailed(err) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	if keep {
+		return nil
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       key,
+	}
+	s.dump(input)
+
+	_, err := s.api.DeleteItemWithContext(ctx, input)
+	return err
+}
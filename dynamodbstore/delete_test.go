@@ -0,0 +1,330 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func makeDeleteStore(t *testing.T) (*memoryTable, *Store) {
+	api := &memoryTable{}
+	api.putEvents("abc", 2, time.Now(), 1, 2, 3, 4, 5)
+
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(2), WithDeletion(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	return api, store
+}
+
+func TestStore_Delete(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("soft", func(t *testing.T) {
+		api, store := makeDeleteStore(t)
+		if err := store.Delete(ctx, "abc", true); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		if _, ok := api.items["abc|-1"][deletedAttribute]; !ok {
+			t.Fatalf("got no marker; want marker")
+		}
+
+		history, err := store.Load(ctx, "abc", 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := versionsOf(history), []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("hard", func(t *testing.T) {
+		api, store := makeDeleteStore(t)
+		name := makeReservationName("email", "a@example.com")
+		api.put(map[string]*dynamodb.AttributeValue{
+			HashKey:  {S: aws.String("abc")},
+			RangeKey: {N: aws.String("-1")},
+			name:     {BOOL: aws.Bool(true)},
+		})
+		api.put(makeReservationWrite("blah", HashKey, RangeKey, "abc", reservation{constraint: "email", value: "a@example.com"}).Put.Item)
+		if err := store.SaveSnapshot(ctx, "abc", Snapshot{Version: 4}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := store.SaveStreamMetadata(ctx, "abc", StreamMetadata{MaxCount: 3}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		api.put(makeIdempotencyWrite("blah", HashKey, RangeKey, "abc", "command", eventsource.Record{Version: 1}).Put.Item)
+		api.put(makeOutboxWrite("blah", HashKey, RangeKey, "abc", 1, 0, OutboxEntry{Data: []byte("a")}).Put.Item)
+		// keys of another aggregate whose id shares the prefix are retained
+		api.put(makeIdempotencyWrite("blah", HashKey, RangeKey, "abc/x", "command", eventsource.Record{Version: 1}).Put.Item)
+
+		if err := store.Delete(ctx, "abc", false); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		want := []string{"abc|0", "abc|1", "abc|2", name + "|0", "$snapshot/abc|0", "$idempotency/abc/command|0", "$outbox/abc/1/0|0", "$metadata/abc|0"}
+		if got := api.deleted; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		head := api.items["abc|-1"]
+		if _, ok := head[deletedAttribute]; !ok {
+			t.Fatalf("got no marker; want marker")
+		}
+		if _, ok := head[name]; ok {
+			t.Fatalf("got reservation; want reservation released")
+		}
+
+		history, err := store.Load(ctx, "abc", 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got := len(history); got != 0 {
+			t.Fatalf("got %v; want 0", got)
+		}
+	})
+}
+
+func TestStore_TruncateBefore(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		Version  int
+		Snapshot int
+		Deleted  []string
+		Expected []int
+		Code     string
+	}{
+		"partition boundary": {
+			Version:  4,
+			Snapshot: 3,
+			Deleted:  []string{"abc|0", "abc|1"},
+			Expected: []int{4, 5},
+		},
+		"within partition": {
+			Version:  3,
+			Snapshot: 5,
+			Deleted:  []string{"abc|0"},
+			Expected: []int{3, 4, 5},
+		},
+		"nothing": {
+			Version:  1,
+			Expected: []int{1, 2, 3, 4, 5},
+		},
+		"beyond snapshot": {
+			Version:  4,
+			Snapshot: 2,
+			Expected: []int{1, 2, 3, 4, 5},
+			Code:     ErrSnapshotRequired,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			api, store := makeDeleteStore(t)
+			if tc.Snapshot > 0 {
+				if err := store.SaveSnapshot(ctx, "abc", Snapshot{Version: tc.Snapshot}); err != nil {
+					t.Fatalf("got %v; want nil", err)
+				}
+			}
+
+			err := store.TruncateBefore(ctx, "abc", tc.Version)
+			if tc.Code != "" {
+				if !eventsource.ErrHasCode(err, tc.Code) {
+					t.Fatalf("got %v; want %v", err, tc.Code)
+				}
+			} else if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			if got := api.deleted; !reflect.DeepEqual(got, tc.Deleted) {
+				t.Fatalf("got %v; want %v", got, tc.Deleted)
+			}
+			if len(tc.Deleted) > 0 {
+				if got, want := *api.items["abc|-1"][truncatedAttribute].N, strconv.Itoa(tc.Version); got != want {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+			}
+
+			history, err := store.Load(ctx, "abc", 0, 0)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got := versionsOf(history); !reflect.DeepEqual(got, tc.Expected) {
+				t.Fatalf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+// rejectingTable fails every update as though its condition was not met
+type rejectingTable struct {
+	*memoryTable
+}
+
+func (r rejectingTable) UpdateItemWithContext(_ aws.Context, _ *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	return nil, awserr.New(awsConditionalCheckFailed, "The conditional request failed", nil)
+}
+
+func (r rejectingTable) TransactWriteItemsWithContext(_ aws.Context, input *dynamodb.TransactWriteItemsInput, _ ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	reasons := make([]string, len(input.TransactItems))
+	for i := range reasons {
+		reasons[i] = awsReasonConditionalCheckFailed
+	}
+	message := "Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(reasons, ", ") + "]"
+	return nil, awserr.New(dynamodb.ErrCodeTransactionCanceledException, message, nil)
+}
+
+func TestStore_SaveDeleted(t *testing.T) {
+	ctx := context.Background()
+
+	api, store := makeDeleteStore(t)
+	if err := store.Delete(ctx, "abc", true); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	store.api = rejectingTable{memoryTable: api}
+	err := store.Save(ctx, "abc", eventsource.Record{Version: 6, Data: []byte("6")})
+	if !eventsource.ErrHasCode(err, ErrAggregateDeleted) {
+		t.Fatalf("got %v; want %v", err, ErrAggregateDeleted)
+	}
+}
+
+func TestStore_SaveMultiDeleted(t *testing.T) {
+	ctx := context.Background()
+
+	api, store := makeDeleteStore(t)
+	if err := store.Delete(ctx, "abc", false); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	store.api = rejectingTable{memoryTable: api}
+	err := store.SaveMulti(ctx, map[string][]eventsource.Record{
+		"abc": {{Version: 6, Data: []byte("6")}},
+		"def": {{Version: 1, Data: []byte("1")}},
+	})
+	if !eventsource.ErrHasCode(err, ErrAggregateDeleted) {
+		t.Fatalf("got %v; want %v", err, ErrAggregateDeleted)
+	}
+}
+
+func TestAddMarkerConditions(t *testing.T) {
+	records := []eventsource.Record{{Version: 3, Data: []byte("c")}}
+	input, err := makeUpdateItemInput("table", HashKey, RangeKey, 2, "abc", records...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	update := makeUpdate(input)
+	addMarkerConditions(update, 3)
+
+	want := "attribute_not_exists(#_3) AND attribute_not_exists(#deleted) AND (attribute_not_exists(#truncated) OR #truncated <= :first)"
+	if got := *update.ConditionExpression; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *update.ExpressionAttributeValues[":first"].N, "3"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_DeleteConditions(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+			WithEventPerItem(2),
+			WithDeletion(true),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		if err := store.Save(ctx, "abc", makeBatch(1, 5, 1)...); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// truncation requires a snapshot that covers the events removed
+		err = store.TruncateBefore(ctx, "abc", 5)
+		if !eventsource.ErrHasCode(err, ErrSnapshotRequired) {
+			t.Fatalf("got %v; want %v", err, ErrSnapshotRequired)
+		}
+		if err := store.SaveSnapshot(ctx, "abc", Snapshot{Version: 4}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// records before the truncation point are rejected by the head, even within removed partitions
+		if err := store.TruncateBefore(ctx, "abc", 5); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		for _, version := range []int{1, 4} {
+			if err := store.Save(ctx, "abc", makeBatch(version, 1, 1)...); err == nil {
+				t.Fatalf("got nil; want not nil")
+			}
+		}
+
+		// truncating beyond the last event does not create the partition holding version
+		if err := store.Save(ctx, "def", makeBatch(1, 3, 1)...); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := store.SaveSnapshot(ctx, "def", Snapshot{Version: 8}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := store.TruncateBefore(ctx, "def", 9); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		out, err := api.GetItem(&dynamodb.GetItemInput{
+			TableName:      aws.String(tableName),
+			Key:            store.makePartitionKey("def", selectPartition(9, 2)),
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if out.Item != nil {
+			t.Fatalf("got %v; want no item", out.Item)
+		}
+
+		if err := store.Delete(ctx, "abc", true); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		err = store.Save(ctx, "abc", makeBatch(6, 1, 1)...)
+		if !eventsource.ErrHasCode(err, ErrAggregateDeleted) {
+			t.Fatalf("got %v; want %v", err, ErrAggregateDeleted)
+		}
+
+		history, err := store.Load(ctx, "abc", 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := versionsOf(history), []int{5}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		// a hard deleted aggregate may not be recreated
+		if err := store.Delete(ctx, "abc", false); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		err = store.Save(ctx, "abc", makeBatch(1, 1, 1)...)
+		if !eventsource.ErrHasCode(err, ErrAggregateDeleted) {
+			t.Fatalf("got %v; want %v", err, ErrAggregateDeleted)
+		}
+		if _, ok, err := store.LoadSnapshot(ctx, "abc"); err != nil || ok {
+			t.Fatalf("got %v, %v; want false, nil", ok, err)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package dynamodbstore
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// OutboxSender delivers outbox messages e.g. by sending an email or calling a webhook.  Messages are
+// delivered at least once so senders should deduplicate on OutboxMessage.ID where it matters.
+type OutboxSender interface {
+	Send(ctx context.Context, message OutboxMessage) error
+}
+
+// OutboxSenderFunc provides a func alternative for declaring an OutboxSender
+type OutboxSenderFunc func(ctx context.Context, message OutboxMessage) error
+
+// Send implements OutboxSender
+func (fn OutboxSenderFunc) Send(ctx context.Context, message OutboxMessage) error {
+	return fn(ctx, message)
+}
+
+// Dispatcher delivers pending outbox messages written by Save and removes them from the outbox once
+// delivered
+type Dispatcher struct {
+	store  *Store
+	sender OutboxSender
+}
+
+// NewDispatcher constructs a Dispatcher for the outbox of the store
+func NewDispatcher(store *Store, sender OutboxSender) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		sender: sender,
+	}
+}
+
+// HandleRecord dispatches the outbox message contained in the stream record, if any.  Intended to be
+// called from a dynamodb stream consumer; records that are not pending outbox items, including the
+// removal of dispatched items, are ignored.
+func (d *Dispatcher) HandleRecord(ctx context.Context, record events.DynamoDBStreamRecord) error {
+	if record.NewImage == nil {
+		return nil
+	}
+	if _, ok := record.NewImage[pendingAttribute]; !ok {
+		return nil
+	}
+
+	message, ok := outboxMessageFromImage(d.store.hashKey, record.NewImage)
+	if !ok {
+		return nil
+	}
+
+	return d.dispatch(ctx, message)
+}
+
+// Poll scans the table for pending outbox messages and dispatches them; returns the number of
+// messages dispatched.  Poll reads the entire table and is intended for recovery or low volume use.
+func (d *Dispatcher) Poll(ctx context.Context) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        aws.String(d.store.tableName),
+		ConsistentRead:   aws.Bool(true),
+		FilterExpression: aws.String("attribute_exists(#pending) AND begins_with(#key, :prefix)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pending": aws.String(pendingAttribute),
+			"#key":     aws.String(d.store.hashKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prefix": {S: aws.String(outboxPrefix)},
+		},
+	}
+
+	count := 0
+	for {
+		out, err := d.store.api.ScanWithContext(ctx, input)
+		if err != nil {
+			return count, err
+		}
+
+		for _, item := range out.Items {
+			message, ok := outboxMessageFromItem(d.store.hashKey, item)
+			if !ok {
+				continue
+			}
+			if err := d.dispatch(ctx, message); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return count, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// dispatch sends the message and deletes its outbox item
+func (d *Dispatcher) dispatch(ctx context.Context, message OutboxMessage) error {
+	if err := d.sender.Send(ctx, message); err != nil {
+		return err
+	}
+
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(d.store.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			d.store.hashKey:  {S: aws.String(outboxPrefix + message.ID)},
+			d.store.rangeKey: {N: aws.String("0")},
+		},
+		ConditionExpression: aws.String("attribute_exists(#pending)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#pending": aws.String(pendingAttribute),
+		},
+	}
+	d.store.dump(input)
+
+	if _, err := d.store.api.DeleteItemWithContext(ctx, input); err != nil {
+		if IsConditionalCheckFailed(err) {
+			// already dispatched by another consumer
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
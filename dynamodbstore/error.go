@@ -1,5 +1,251 @@
 package dynamodbstore
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
 const (
 	awsConditionalCheckFailed = "ConditionalCheckFailedException"
+
+	// awsReasonConditionalCheckFailed is the cancellation reason reported for a transaction item
+	// whose condition was not met
+	awsReasonConditionalCheckFailed = "ConditionalCheckFailed"
+
+	// awsReasonTransactionConflict is the cancellation reason reported for a transaction item that
+	// another transaction was writing at the same time
+	awsReasonTransactionConflict = "TransactionConflict"
 )
+
+const (
+	// ErrVersionConflict is the code used when the records being saved do not immediately follow
+	// the current version of the aggregate or conflict with records previously saved
+	ErrVersionConflict = "VersionConflict"
+
+	// ErrIdempotencyConflict is the code used when an idempotency key is reused with different records
+	ErrIdempotencyConflict = "IdempotencyConflict"
+
+	// ErrConflict is the code used when records could not be saved because they conflict with
+	// records previously saved
+	ErrConflict = "Conflict"
+
+	// ErrConstraintViolation is the code used when a value reserved by Save is held by another aggregate
+	ErrConstraintViolation = "ConstraintViolation"
+
+	// ErrInvalidReservation is the code used when a single save both reserves and releases a value
+	ErrInvalidReservation = "InvalidReservation"
+
+	// ErrLimitExceeded is the code used when a write would exceed one of the dynamodb service limits
+	ErrLimitExceeded = "LimitExceeded"
+
+	// ErrUpcast is the code used when a loaded event could not be rewritten into its current schema
+	ErrUpcast = "UpcastFailed"
+
+	// ErrAggregateDeleted is the code used when records are saved to an aggregate that has been deleted
+	ErrAggregateDeleted = "AggregateDeleted"
+
+	// ErrDeletionDisabled is the code used when Delete or TruncateBefore is called on a store created
+	// without WithDeletion
+	ErrDeletionDisabled = "DeletionDisabled"
+
+	// ErrSnapshotRequired is the code used when TruncateBefore would remove events not yet folded into
+	// a snapshot
+	ErrSnapshotRequired = "SnapshotRequired"
+
+	// ErrPositionContention is the code used when Save repeatedly loses the race to assign global
+	// positions to other saves
+	ErrPositionContention = "PositionContention"
+
+	// ErrIntegrity is the code used when a stream record shows committed events being modified or removed
+	ErrIntegrity = "IntegrityViolation"
+
+	// ErrInvalidARN is the code used when an arn is not a valid dynamodb table, stream, or index arn
+	ErrInvalidARN = "InvalidARN"
+)
+
+// VersionError is returned by Save when strict versioning is enabled and the records provided are
+// either not contiguous or do not immediately follow the current version of the aggregate.
+// VersionError satisfies eventsource.Error
+type VersionError struct {
+	// AggregateID holds the id of the aggregate being saved
+	AggregateID string
+
+	// Expected holds the version the record should have had
+	Expected int
+
+	// Actual holds the version the record provided had
+	Actual int
+}
+
+// Cause implements eventsource.Error
+func (e *VersionError) Cause() error { return nil }
+
+// Code implements eventsource.Error
+func (e *VersionError) Code() string { return ErrVersionConflict }
+
+// Message implements eventsource.Error
+func (e *VersionError) Message() string {
+	return fmt.Sprintf("expected version %v for aggregate, %v; got %v", e.Expected, e.AggregateID, e.Actual)
+}
+
+// Error implements error
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("[%v] %v", e.Code(), e.Message())
+}
+
+// ConflictError is returned by SaveMulti when the records for one or more aggregates conflict with
+// records previously saved.  ConflictError satisfies eventsource.Error
+type ConflictError struct {
+	// AggregateIDs holds the ids of the conflicting aggregates
+	AggregateIDs []string
+}
+
+// Cause implements eventsource.Error
+func (e *ConflictError) Cause() error { return nil }
+
+// Code implements eventsource.Error
+func (e *ConflictError) Code() string { return ErrConflict }
+
+// Message implements eventsource.Error
+func (e *ConflictError) Message() string {
+	return fmt.Sprintf("conflicting records for aggregate(s), %v", strings.Join(e.AggregateIDs, ", "))
+}
+
+// Error implements error
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("[%v] %v", e.Code(), e.Message())
+}
+
+// LimitError is returned by Save and SaveMulti when a write would exceed one of the dynamodb service
+// limits.  The write is rejected before it is sent.  LimitError satisfies eventsource.Error
+type LimitError struct {
+	// Limit names the limit that would be exceeded; one of the Limit constants
+	Limit string
+
+	// Size holds the size, or count, the write would have had
+	Size int
+
+	// Max holds the largest size, or count, dynamodb allows
+	Max int
+}
+
+// Cause implements eventsource.Error
+func (e *LimitError) Cause() error { return nil }
+
+// Code implements eventsource.Error
+func (e *LimitError) Code() string { return ErrLimitExceeded }
+
+// Message implements eventsource.Error
+func (e *LimitError) Message() string {
+	return fmt.Sprintf("%v of %v exceeds the dynamodb limit of %v", e.Limit, e.Size, e.Max)
+}
+
+// Error implements error
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("[%v] %v", e.Code(), e.Message())
+}
+
+// IntegrityError is returned when parsing a stream record that modifies or removes events that were
+// previously committed, e.g. an edit made from the console or an item deleted or expired by ttl.
+// IntegrityError satisfies eventsource.Error
+type IntegrityError struct {
+	// AggregateID holds the id of the aggregate whose events were altered
+	AggregateID string
+
+	// Partition holds the range key of the item that was altered
+	Partition int
+
+	// SequenceNumber holds the position of the offending record within its stream shard
+	SequenceNumber string
+
+	// Modified holds the versions of the events whose contents changed
+	Modified []int
+
+	// Removed holds the versions of the events that no longer exist
+	Removed []int
+}
+
+// Cause implements eventsource.Error
+func (e *IntegrityError) Cause() error { return nil }
+
+// Code implements eventsource.Error
+func (e *IntegrityError) Code() string { return ErrIntegrity }
+
+// Message implements eventsource.Error
+func (e *IntegrityError) Message() string {
+	return fmt.Sprintf("committed events altered for aggregate, %v, partition %v; modified %v, removed %v", e.AggregateID, e.Partition, e.Modified, e.Removed)
+}
+
+// Error implements error
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("[%v] %v", e.Code(), e.Message())
+}
+
+// newConflictError returns the error reported when records could not be saved because versions they
+// use already hold other records
+func newConflictError(aggregateID string) error {
+	return eventsource.NewError(nil, ErrVersionConflict, "records conflict with those previously saved for aggregate, %v", aggregateID)
+}
+
+// cancellationReasons extracts the per item reasons from a TransactionCanceledException.  The reasons
+// are only available from the error message e.g.
+//
+//	Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]
+func cancellationReasons(err awserr.Error) []string {
+	message := err.Message()
+	begin := strings.LastIndex(message, "[")
+	end := strings.LastIndex(message, "]")
+	if begin < 0 || end < begin {
+		return nil
+	}
+
+	var reasons []string
+	for _, reason := range strings.Split(message[begin+1:end], ",") {
+		reasons = append(reasons, strings.TrimSpace(reason))
+	}
+
+	return reasons
+}
+
+// IsConditionalCheckFailed returns true if the write failed because one of its conditions was
+// not met; handles both single item and TransactWriteItems failures
+func IsConditionalCheckFailed(err error) bool {
+	v, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+
+	switch v.Code() {
+	case awsConditionalCheckFailed:
+		return true
+	case dynamodb.ErrCodeTransactionCanceledException:
+		for _, reason := range cancellationReasons(v) {
+			if reason == awsReasonConditionalCheckFailed {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isTransactionConflict returns true if the transaction was cancelled because another transaction
+// was writing one of its items at the same time
+func isTransactionConflict(err error) bool {
+	v, ok := err.(awserr.Error)
+	if !ok || v.Code() != dynamodb.ErrCodeTransactionCanceledException {
+		return false
+	}
+
+	for _, reason := range cancellationReasons(v) {
+		if reason == awsReasonTransactionConflict {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package dynamodbstore
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestCancellationReasons(t *testing.T) {
+	err := awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]", nil)
+	if got, want := cancellationReasons(err), []string{"None", "ConditionalCheckFailed"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	err = awserr.New(dynamodb.ErrCodeTransactionCanceledException, "bogus", nil)
+	if got := cancellationReasons(err); got != nil {
+		t.Fatalf("got %v; want nil", got)
+	}
+}
+
+func TestIsConditionalCheckFailed(t *testing.T) {
+	testCases := map[string]struct {
+		Err      error
+		Expected bool
+	}{
+		"update": {
+			Err:      awserr.New(awsConditionalCheckFailed, "The conditional request failed", nil),
+			Expected: true,
+		},
+		"transaction": {
+			Err:      awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [ConditionalCheckFailed, None]", nil),
+			Expected: true,
+		},
+		"transaction-conflict": {
+			Err: awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [TransactionConflict, None]", nil),
+		},
+		"other": {
+			Err: errors.New("boom"),
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if got, want := IsConditionalCheckFailed(tc.Err), tc.Expected; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package dynamodbstore
+
+import (
+	"context"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// EventStore saves and loads decoded events rather than raw records.  Events are encoded using the
+// Registry so the type name of each event is stored alongside it.
+type EventStore struct {
+	store    eventsource.Store
+	registry *Registry
+}
+
+// NewEventStore returns an EventStore backed by store, typically a *Store
+func NewEventStore(store eventsource.Store, registry *Registry) *EventStore {
+	return &EventStore{
+		store:    store,
+		registry: registry,
+	}
+}
+
+// SaveEvents encodes and saves the events to the aggregate.  Each event is saved at its EventVersion.
+func (e *EventStore) SaveEvents(ctx context.Context, aggregateID string, events ...eventsource.Event) error {
+	records := make([]eventsource.Record, 0, len(events))
+	for _, event := range events {
+		record, err := e.registry.MarshalEvent(event)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+
+	return e.store.Save(ctx, aggregateID, records...)
+}
+
+// LoadEvents returns the decoded events of the aggregate ordered by version; ready to be applied
+func (e *EventStore) LoadEvents(ctx context.Context, aggregateID string) ([]eventsource.Event, error) {
+	history, err := e.store.Load(ctx, aggregateID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]eventsource.Event, 0, len(history))
+	for _, record := range history {
+		event, err := e.registry.UnmarshalEvent(record)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,60 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// recordStore is a minimal in memory eventsource.Store
+type recordStore map[string]eventsource.History
+
+func (r recordStore) Save(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
+	r[aggregateID] = append(r[aggregateID], records...)
+	return nil
+}
+
+func (r recordStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	return r[aggregateID], nil
+}
+
+func TestEventStore(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(OrderCreated{}, NewJSONCodec(OrderCreated{}))
+	registry.Register(OrderShipped{}, NewJSONCodec(OrderShipped{}))
+
+	var (
+		ctx     = context.Background()
+		records = recordStore{}
+		store   = NewEventStore(records, registry)
+	)
+
+	events := []eventsource.Event{
+		&OrderCreated{Model: eventsource.Model{ID: "abc", Version: 1}, Customer: "alice"},
+		&OrderShipped{Model: eventsource.Model{ID: "abc", Version: 2}, Carrier: "ups"},
+	}
+	if err := store.SaveEvents(ctx, "abc", events...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// type names are stored with each event
+	for i, want := range []string{"OrderCreated", "OrderShipped"} {
+		envelope, err := readEnvelope(records["abc"][i].Data)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got := envelope.Type; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	found, err := store.LoadEvents(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if !reflect.DeepEqual(found, events) {
+		t.Fatalf("got %#v; want %#v", found, events)
+	}
+}
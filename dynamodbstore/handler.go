@@ -0,0 +1,120 @@
+package dynamodbstore
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// StreamBatchItemFailure identifies, by sequence number, a stream record lambda should retry
+type StreamBatchItemFailure struct {
+	ItemIdentifier string `json:"itemIdentifier"`
+}
+
+// StreamResponse is the partial batch response understood by lambda event source mappings configured
+// with the ReportBatchItemFailures function response type
+type StreamResponse struct {
+	BatchItemFailures []StreamBatchItemFailure `json:"batchItemFailures"`
+}
+
+// streamGroup holds the records of a single aggregate within a batch
+type streamGroup struct {
+	aggregateID    string
+	sequenceNumber string // sequenceNumber of the first record in the group
+	records        []eventsource.Record
+	failed         bool
+}
+
+// IntegrityAlerter is notified of stream records that modify or remove committed events
+type IntegrityAlerter func(ctx context.Context, violation *IntegrityError) error
+
+// StreamHandlerOption represents a functional configuration of StreamHandler
+type StreamHandlerOption func(*streamHandlerOptions)
+
+type streamHandlerOptions struct {
+	alert IntegrityAlerter
+}
+
+// WithIntegrityAlerter specifies where StreamHandler reports integrity violations; by default they
+// are logged
+func WithIntegrityAlerter(alert IntegrityAlerter) StreamHandlerOption {
+	return func(o *streamHandlerOptions) {
+		o.alert = alert
+	}
+}
+
+// LogIntegrityViolation is the default IntegrityAlerter; it logs the violation
+func LogIntegrityViolation(_ context.Context, violation *IntegrityError) error {
+	log.Printf("integrity violation - %v\n", violation)
+	return nil
+}
+
+// StreamHandler adapts fn into a lambda handler for the dynamodb stream of an eventsource table.
+// Records in the batch are grouped by aggregate and fn is called once per aggregate with its new
+// events in order.  Stream records that hold no new events are skipped.
+//
+// Integrity violations are permanent so the offending stream records are passed to the alerter, see
+// WithIntegrityAlerter, rather than retried.  Only the events such records append are delivered.
+//
+// When fn fails, a stream record cannot be parsed, or a violation cannot be alerted, the first stream
+// record of the aggregate is reported as a batch item failure.  Lambda resumes from the lowest failed
+// sequence number so that record and every later record in the batch, including those of other
+// aggregates, are redelivered; fn must tolerate receiving events more than once.
+func StreamHandler(fn func(ctx context.Context, aggregateID string, records []eventsource.Record) error, opts ...StreamHandlerOption) func(ctx context.Context, event events.DynamoDBEvent) (StreamResponse, error) {
+	options := streamHandlerOptions{
+		alert: LogIntegrityViolation,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(ctx context.Context, event events.DynamoDBEvent) (StreamResponse, error) {
+		var (
+			response StreamResponse
+			groups   []*streamGroup
+			index    = map[string]*streamGroup{}
+		)
+
+		for _, record := range event.Records {
+			sequenceNumber := record.Change.SequenceNumber
+
+			changeSet, err := ParseEventRecord(record)
+			if v, ok := err.(*IntegrityError); ok {
+				err = options.alert(ctx, v)
+			} else if err != nil {
+				response.BatchItemFailures = append(response.BatchItemFailures, StreamBatchItemFailure{ItemIdentifier: sequenceNumber})
+				continue
+			}
+
+			group, ok := index[changeSet.AggregateID]
+			if !ok {
+				group = &streamGroup{aggregateID: changeSet.AggregateID}
+				index[changeSet.AggregateID] = group
+				groups = append(groups, group)
+			}
+			if err != nil || len(changeSet.Records) > 0 {
+				if group.sequenceNumber == "" {
+					group.sequenceNumber = sequenceNumber
+				}
+			}
+			if err != nil {
+				group.failed = true
+				continue
+			}
+			group.records = append(group.records, changeSet.Records...)
+		}
+
+		for _, group := range groups {
+			if !group.failed && len(group.records) > 0 {
+				group.failed = fn(ctx, group.aggregateID, group.records) != nil
+			}
+			if group.failed {
+				response.BatchItemFailures = append(response.BatchItemFailures, StreamBatchItemFailure{ItemIdentifier: group.sequenceNumber})
+			}
+		}
+
+		return response, nil
+	}
+}
@@ -0,0 +1,121 @@
+package dynamodbstore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func makeEventRecord(sequenceNumber, aggregateID string, versions ...int) events.DynamoDBEventRecord {
+	image := map[string]events.DynamoDBAttributeValue{}
+	for _, version := range versions {
+		image[makeKey(version)] = events.NewBinaryAttribute([]byte(aggregateID))
+	}
+
+	return events.DynamoDBEventRecord{
+		EventName: "MODIFY",
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: sequenceNumber,
+			Keys: map[string]events.DynamoDBAttributeValue{
+				HashKey:  events.NewStringAttribute(aggregateID),
+				RangeKey: events.NewNumberAttribute("0"),
+			},
+			NewImage: image,
+		},
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	type call struct {
+		AggregateID string
+		Versions    []int
+	}
+
+	var (
+		calls      []call
+		violations []string
+		failDEF    = errors.New("boom")
+	)
+	alert := func(ctx context.Context, violation *IntegrityError) error {
+		violations = append(violations, violation.AggregateID)
+		if violation.AggregateID == "jkl" {
+			return errors.New("unable to alert")
+		}
+		return nil
+	}
+	handler := StreamHandler(func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		c := call{AggregateID: aggregateID}
+		for _, record := range records {
+			c.Versions = append(c.Versions, record.Version)
+		}
+		calls = append(calls, c)
+
+		if aggregateID == "def" {
+			return failDEF
+		}
+		return nil
+	}, WithIntegrityAlerter(alert))
+
+	removed := makeEventRecord("7", "ghi")
+	removed.EventName = "REMOVE"
+	removed.Change.OldImage = map[string]events.DynamoDBAttributeValue{
+		"_1": events.NewBinaryAttribute([]byte("ghi")),
+	}
+	// alters a committed event while appending another
+	tampered := makeEventRecord("11", "mno", 1, 2)
+	tampered.Change.OldImage = map[string]events.DynamoDBAttributeValue{
+		"_1": events.NewBinaryAttribute([]byte("original")),
+	}
+	unalerted := makeEventRecord("9", "jkl")
+	unalerted.EventName = "REMOVE"
+	unalerted.Change.OldImage = map[string]events.DynamoDBAttributeValue{
+		"_1": events.NewBinaryAttribute([]byte("jkl")),
+	}
+
+	response, err := handler(context.Background(), events.DynamoDBEvent{
+		Records: []events.DynamoDBEventRecord{
+			makeEventRecord("1", "abc", 1),
+			makeEventRecord("2", "def", 1, 2),
+			makeEventRecord("3", "abc", 2, 3),
+			makeEventRecord("4", "xyz"), // no new events
+			makeEventRecord("5", "def", 3),
+			{EventName: "MODIFY", Change: events.DynamoDBStreamRecord{SequenceNumber: "6"}},
+			removed,
+			makeEventRecord("8", "ghi", 2),
+			unalerted,
+			makeEventRecord("10", "jkl", 2),
+			tampered,
+		},
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	wantCalls := []call{
+		{AggregateID: "abc", Versions: []int{1, 2, 3}},
+		{AggregateID: "def", Versions: []int{1, 2, 3}},
+		{AggregateID: "ghi", Versions: []int{2}},
+		{AggregateID: "mno", Versions: []int{2}},
+	}
+	if !reflect.DeepEqual(calls, wantCalls) {
+		t.Fatalf("got %v; want %v", calls, wantCalls)
+	}
+
+	wantResponse := StreamResponse{
+		BatchItemFailures: []StreamBatchItemFailure{
+			{ItemIdentifier: "6"}, // unparseable
+			{ItemIdentifier: "2"}, // handler failed
+			{ItemIdentifier: "9"}, // integrity violation not alerted
+		},
+	}
+	if !reflect.DeepEqual(response, wantResponse) {
+		t.Fatalf("got %#v; want %#v", response, wantResponse)
+	}
+	if got, want := violations, []string{"ghi", "jkl", "mno"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// headPartition is the range key of the item that tracks the current version of an aggregate.  It
+	// sorts ahead of every partition that holds events so ranged queries never see it
+	headPartition = -1
+
+	// headAttribute holds the version of the most recent event saved to the aggregate when strict
+	// versioning is enabled.  The head item also holds the deletion and truncation markers of the
+	// aggregate along with the reservations it holds.
+	headAttribute = "head"
+)
+
+func makeHeadKey(hashKey, rangeKey, aggregateID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		hashKey:  {S: aws.String(aggregateID)},
+		rangeKey: {N: aws.String(strconv.Itoa(headPartition))},
+	}
+}
+
+// makeHeadUpdate advances the head of the aggregate to the last of the records provided, but only
+// if the first record immediately follows the current head.  Records are assumed sorted.
+func makeHeadUpdate(tableName, hashKey, rangeKey, aggregateID string, records ...eventsource.Record) *dynamodb.Update {
+	var (
+		first = records[0].Version
+		last  = records[len(records)-1].Version
+	)
+
+	update := &dynamodb.Update{
+		TableName:        aws.String(tableName),
+		Key:              makeHeadKey(hashKey, rangeKey, aggregateID),
+		UpdateExpression: aws.String("SET #head = :head"),
+		ExpressionAttributeNames: map[string]*string{
+			"#head": aws.String(headAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":head": {N: aws.String(strconv.Itoa(last))},
+		},
+	}
+
+	if first == 1 {
+		update.ConditionExpression = aws.String("attribute_not_exists(#head)")
+	} else {
+		update.ConditionExpression = aws.String("#head = :prev")
+		update.ExpressionAttributeValues[":prev"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(first - 1))}
+	}
+
+	return update
+}
+
+// makeHeadWrite returns the write to the head item made by the save; nil if the save need not touch
+// the head.  The write advances the head when strict versioning is enabled, records the reservations
+// claimed or released by the save, and, when WithDeletion is enabled, is rejected once the aggregate
+// is deleted or when the records precede its truncation point.  Otherwise the head is only checked.
+func (s *Store) makeHeadWrite(aggregateID string, reservations []reservation, records ...eventsource.Record) *dynamodb.TransactWriteItem {
+	if !s.strict && !s.deletion && len(reservations) == 0 {
+		return nil
+	}
+
+	update := &dynamodb.Update{
+		TableName:                 aws.String(s.tableName),
+		Key:                       makeHeadKey(s.hashKey, s.rangeKey, aggregateID),
+		ExpressionAttributeNames:  map[string]*string{},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{},
+	}
+	if s.strict {
+		update = makeHeadUpdate(s.tableName, s.hashKey, s.rangeKey, aggregateID, records...)
+	}
+	if s.deletion {
+		addMarkerConditions(update, records[0].Version)
+	}
+	addReservationAttributes(update, reservations)
+
+	if update.UpdateExpression == nil {
+		return &dynamodb.TransactWriteItem{
+			ConditionCheck: &dynamodb.ConditionCheck{
+				TableName:                 update.TableName,
+				Key:                       update.Key,
+				ConditionExpression:       update.ConditionExpression,
+				ExpressionAttributeNames:  update.ExpressionAttributeNames,
+				ExpressionAttributeValues: update.ExpressionAttributeValues,
+			},
+		}
+	}
+
+	return &dynamodb.TransactWriteItem{Update: update}
+}
+
+// loadHead returns the current version of the aggregate as recorded by the head item; 0 if the
+// aggregate has no head
+func (s *Store) loadHead(ctx context.Context, aggregateID string) (int, error) {
+	item, err := s.loadHeadItem(ctx, aggregateID)
+	if err != nil {
+		return 0, err
+	}
+
+	av, ok := item[headAttribute]
+	if !ok || av.N == nil {
+		return 0, nil
+	}
+
+	return strconv.Atoi(*av.N)
+}
+
+// loadHeadItem returns the head item of the aggregate; nil if there is none
+func (s *Store) loadHeadItem(ctx context.Context, aggregateID string) (map[string]*dynamodb.AttributeValue, error) {
+	out, err := s.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            makeHeadKey(s.hashKey, s.rangeKey, aggregateID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Item, nil
+}
+
+// validateSequence ensures the sorted records provided have contiguous version numbers
+func validateSequence(aggregateID string, records ...eventsource.Record) error {
+	for i := 1; i < len(records); i++ {
+		if want := records[i-1].Version + 1; records[i].Version != want {
+			return &VersionError{
+				AggregateID: aggregateID,
+				Expected:    want,
+				Actual:      records[i].Version,
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package dynamodbstore
+
+import (
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestValidateSequence(t *testing.T) {
+	testCases := map[string]struct {
+		Records  []eventsource.Record
+		Expected int
+		Actual   int
+	}{
+		"contiguous": {
+			Records: []eventsource.Record{{Version: 1}, {Version: 2}, {Version: 3}},
+		},
+		"single": {
+			Records: []eventsource.Record{{Version: 5}},
+		},
+		"gap": {
+			Records:  []eventsource.Record{{Version: 1}, {Version: 2}, {Version: 5}},
+			Expected: 3,
+			Actual:   5,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			err := validateSequence("abc", tc.Records...)
+			if tc.Expected == 0 {
+				if err != nil {
+					t.Fatalf("got %v; want nil", err)
+				}
+				return
+			}
+
+			v, ok := err.(*VersionError)
+			if !ok {
+				t.Fatalf("got %v; want *VersionError", err)
+			}
+			if got, want := v.Expected, tc.Expected; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := v.Actual, tc.Actual; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if !eventsource.ErrHasCode(err, ErrVersionConflict) {
+				t.Fatalf("got false; want true")
+			}
+		})
+	}
+}
+
+func TestMakeHeadUpdate(t *testing.T) {
+	t.Run("first", func(t *testing.T) {
+		update := makeHeadUpdate("table", HashKey, RangeKey, "abc", eventsource.Record{Version: 1}, eventsource.Record{Version: 2})
+		if got, want := *update.ConditionExpression, "attribute_not_exists(#head)"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *update.ExpressionAttributeValues[":head"].N, "2"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *update.Key[RangeKey].N, "-1"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("next", func(t *testing.T) {
+		update := makeHeadUpdate("table", HashKey, RangeKey, "abc", eventsource.Record{Version: 3})
+		if got, want := *update.ConditionExpression, "#head = :prev"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *update.ExpressionAttributeValues[":prev"].N, "2"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestStore_MakeHeadWrite(t *testing.T) {
+	records := []eventsource.Record{{Version: 3}}
+	markers := "attribute_not_exists(#deleted) AND (attribute_not_exists(#truncated) OR #truncated <= :first)"
+
+	t.Run("none", func(t *testing.T) {
+		store := &Store{tableName: "table", hashKey: HashKey, rangeKey: RangeKey}
+		if item := store.makeHeadWrite("abc", nil, records...); item != nil {
+			t.Fatalf("got %v; want nil", item)
+		}
+	})
+
+	t.Run("check", func(t *testing.T) {
+		store := &Store{tableName: "table", hashKey: HashKey, rangeKey: RangeKey, deletion: true}
+		item := store.makeHeadWrite("abc", nil, records...)
+		if item.ConditionCheck == nil {
+			t.Fatalf("got nil; want ConditionCheck")
+		}
+		if got, want := *item.ConditionCheck.ConditionExpression, markers; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *item.ConditionCheck.Key[RangeKey].N, "-1"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("reservations", func(t *testing.T) {
+		store := &Store{tableName: "table", hashKey: HashKey, rangeKey: RangeKey, deletion: true}
+		item := store.makeHeadWrite("abc", []reservation{{constraint: "email", value: "a@example.com"}}, records...)
+		if item.Update == nil {
+			t.Fatalf("got nil; want Update")
+		}
+		if got, want := *item.Update.UpdateExpression, "SET #r0 = :reserved"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *item.Update.ConditionExpression, markers; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		store := &Store{tableName: "table", hashKey: HashKey, rangeKey: RangeKey, strict: true, deletion: true}
+		item := store.makeHeadWrite("abc", nil, records...)
+		if item.Update == nil {
+			t.Fatalf("got nil; want Update")
+		}
+		if got, want := *item.Update.ConditionExpression, "#head = :prev AND "+markers; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
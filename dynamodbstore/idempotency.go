@@ -0,0 +1,92 @@
+package dynamodbstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// idempotencyPrefix prefixes the hash key of the items that record the idempotency keys used by
+	// each aggregate
+	idempotencyPrefix = reservedPrefix + "idempotency/"
+
+	// tokenAttribute holds the digest of the records saved with the idempotency key
+	tokenAttribute = "token"
+)
+
+// digestRecords returns a digest of the versions and contents of the records
+func digestRecords(records ...eventsource.Record) []byte {
+	h := sha256.New()
+	buf := make([]byte, 8)
+	for _, record := range records {
+		binary.BigEndian.PutUint64(buf, uint64(record.Version))
+		h.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(len(record.Data)))
+		h.Write(buf)
+		h.Write(record.Data)
+	}
+	return h.Sum(nil)
+}
+
+func makeIdempotencyKey(hashKey, rangeKey, aggregateID, idempotencyKey string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		hashKey:  {S: aws.String(idempotencyPrefix + aggregateID + "/" + idempotencyKey)},
+		rangeKey: {N: aws.String(strconv.Itoa(0))},
+	}
+}
+
+// makeIdempotencyWrite records the idempotency key in an item of its own, written in the same
+// transaction as the records.  The key may only be written once per aggregate regardless of the
+// partitions the records fall within.  The item names the aggregate so a hard delete can find it.
+func makeIdempotencyWrite(tableName, hashKey, rangeKey, aggregateID, idempotencyKey string, records ...eventsource.Record) *dynamodb.TransactWriteItem {
+	item := makeIdempotencyKey(hashKey, rangeKey, aggregateID, idempotencyKey)
+	item[tokenAttribute] = &dynamodb.AttributeValue{B: digestRecords(records...)}
+	item[aggregateAttribute] = &dynamodb.AttributeValue{S: aws.String(aggregateID)}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(#token)"),
+			ExpressionAttributeNames: map[string]*string{
+				"#token": aws.String(tokenAttribute),
+			},
+		},
+	}
+}
+
+// checkIdempotencyKey reads the idempotency key of the aggregate.  Returns nil if the key was saved
+// with the same records, ErrIdempotencyConflict if the key was saved with different records, and
+// ErrVersionConflict if the key was never saved.
+func (s *Store) checkIdempotencyKey(ctx context.Context, aggregateID, idempotencyKey string, records ...eventsource.Record) error {
+	out, err := s.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            aws.String(s.tableName),
+		Key:                  makeIdempotencyKey(s.hashKey, s.rangeKey, aggregateID, idempotencyKey),
+		ConsistentRead:       aws.Bool(true),
+		ProjectionExpression: aws.String("#token"),
+		ExpressionAttributeNames: map[string]*string{
+			"#token": aws.String(tokenAttribute),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	av, ok := out.Item[tokenAttribute]
+	if !ok {
+		return newConflictError(aggregateID)
+	}
+
+	if !bytes.Equal(av.B, digestRecords(records...)) {
+		return eventsource.NewError(nil, ErrIdempotencyConflict, "idempotency key, %v, was previously used with different records for aggregate, %v", idempotencyKey, aggregateID)
+	}
+
+	return nil
+}
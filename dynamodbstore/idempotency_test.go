@@ -0,0 +1,36 @@
+package dynamodbstore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestDigestRecords(t *testing.T) {
+	a := digestRecords(eventsource.Record{Version: 1, Data: []byte("ab")}, eventsource.Record{Version: 2, Data: []byte("c")})
+	b := digestRecords(eventsource.Record{Version: 1, Data: []byte("ab")}, eventsource.Record{Version: 2, Data: []byte("c")})
+	if !bytes.Equal(a, b) {
+		t.Fatalf("got %x; want %x", a, b)
+	}
+
+	c := digestRecords(eventsource.Record{Version: 1, Data: []byte("a")}, eventsource.Record{Version: 2, Data: []byte("bc")})
+	if bytes.Equal(a, c) {
+		t.Fatalf("got %x; want different digest", c)
+	}
+}
+
+func TestMakeIdempotencyWrite(t *testing.T) {
+	records := []eventsource.Record{{Version: 1, Data: []byte("a")}}
+	put := makeIdempotencyWrite("table", HashKey, RangeKey, "abc", "command-1", records...).Put
+
+	if got, want := *put.Item[HashKey].S, "$idempotency/abc/command-1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := put.Item[tokenAttribute].B, digestRecords(records...); !bytes.Equal(got, want) {
+		t.Fatalf("got %x; want %x", got, want)
+	}
+	if got, want := *put.ConditionExpression, "attribute_not_exists(#token)"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -26,6 +26,7 @@ type infraOptions struct {
 	readCapacity               int64
 	writeCapacity              int64
 	pointInTimeRecoveryEnabled bool
+	ttlEnabled                 bool
 	isLocal                    bool
 	sns                        struct {
 		topicNames []string
@@ -80,6 +81,14 @@ func WithPointInTimeRecovery(enabled bool) InfraOption {
 	}
 }
 
+// WithTTL enables dynamodb time to live on the table using ExpiresAttribute.  Required by scavengers
+// created WithExpiry.
+func WithTTL(enabled bool) InfraOption {
+	return func(i *infraOptions) {
+		i.ttlEnabled = enabled
+	}
+}
+
 func WithSNS(topicNames ...string) InfraOption {
 	return func(i *infraOptions) {
 		i.sns.topicNames = append(i.sns.topicNames, topicNames...)
@@ -264,6 +273,12 @@ func CreateTableIfNotExists(ctx context.Context, api dynamodbiface.DynamoDBAPI,
 		return err
 	}
 
+	// update time to live settings
+	//
+	if err := updateTTL(ctx, api, tableName, opts...); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -310,3 +325,37 @@ func updateBackups(ctx context.Context, api dynamodbiface.DynamoDBAPI, tableName
 		return nil
 	}
 }
+
+func updateTTL(ctx context.Context, api dynamodbiface.DynamoDBAPI, tableName string, opts ...InfraOption) error {
+	options := makeInfraOptions(opts...)
+
+	if !options.ttlEnabled {
+		return nil
+	}
+
+	output, err := api.DescribeTimeToLiveWithContext(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return fmt.Errorf("unable to determine time to live status for table, %v - %v", tableName, err)
+	}
+
+	if d := output.TimeToLiveDescription; d != nil {
+		switch aws.StringValue(d.TimeToLiveStatus) {
+		case dynamodb.TimeToLiveStatusEnabled, dynamodb.TimeToLiveStatusEnabling:
+			return nil
+		}
+	}
+
+	input := dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(tableName),
+		TimeToLiveSpecification: &dynamodb.TimeToLiveSpecification{
+			AttributeName: aws.String(ExpiresAttribute),
+			Enabled:       aws.Bool(true),
+		},
+	}
+	if _, err := api.UpdateTimeToLiveWithContext(ctx, &input); err != nil {
+		return fmt.Errorf("unable to enable time to live for table, %v - %v", tableName, err)
+	}
+
+	log.Println("enabling time to live for table,", tableName)
+	return nil
+}
@@ -0,0 +1,129 @@
+package dynamodbstore
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// checkIntegrity verifies the stream record leaves every committed event untouched.  Events are only
+// ever appended so any event in the old image must appear, unchanged, in the new image.  Items under
+// reserved keys and the head item hold no events and are exempt, as are events removed by
+// TruncateBefore and removals of items marked as expired whose events all fall outside the retention
+// window recorded by the mark.
+//
+// Modifications can only be detected when the stream includes old images; removals of whole items
+// are always detected.
+func checkIntegrity(eventName events.DynamoDBOperationType, aggregateID string, partition int, change events.DynamoDBStreamRecord) error {
+	if isReservedKey(aggregateID) || partition == headPartition {
+		return nil
+	}
+
+	// items are marked before the scavenger, or dynamodb TTL, removes them
+	if eventName == events.DynamoDBOperationTypeRemove && outsideWindow(change.OldImage) {
+		return nil
+	}
+
+	// events are deliberately removed from before the truncation point
+	floor := 0
+	if av, ok := change.NewImage[truncatedAttribute]; ok && av.DataType() == events.DataTypeNumber {
+		floor, _ = strconv.Atoi(av.Number())
+	}
+
+	var modified, removed []int
+	for key, before := range change.OldImage {
+		if !isKey(key) {
+			continue
+		}
+
+		version, err := versionFromKey(key)
+		if err != nil {
+			return err
+		}
+
+		after, ok := change.NewImage[key]
+		switch {
+		case eventName == events.DynamoDBOperationTypeRemove:
+			removed = append(removed, version)
+		case !ok && version >= floor:
+			removed = append(removed, version)
+		case !ok:
+			// removed deliberately
+		case !sameBinary(before, after):
+			modified = append(modified, version)
+		}
+	}
+
+	if len(modified) == 0 && len(removed) == 0 && eventName != events.DynamoDBOperationTypeRemove {
+		return nil
+	}
+
+	sort.Ints(modified)
+	sort.Ints(removed)
+
+	return &IntegrityError{
+		AggregateID:    aggregateID,
+		Partition:      partition,
+		SequenceNumber: change.SequenceNumber,
+		Modified:       modified,
+		Removed:        removed,
+	}
+}
+
+// outsideWindow returns true if the image was marked for removal and every event within it falls
+// outside the retention window recorded by the mark
+func outsideWindow(image map[string]events.DynamoDBAttributeValue) bool {
+	if _, ok := image[ExpiresAttribute]; !ok {
+		return false
+	}
+	av, ok := image[retainFromAttribute]
+	if !ok || av.DataType() != events.DataTypeNumber {
+		return false
+	}
+	fromVersion, err := strconv.Atoi(av.Number())
+	if err != nil {
+		return false
+	}
+
+	notBefore := int64(math.MinInt64)
+	if av, ok := image[retainSinceAttribute]; ok && av.DataType() == events.DataTypeNumber {
+		if notBefore, err = strconv.ParseInt(av.Number(), 10, 64); err != nil {
+			return false
+		}
+	}
+
+	for key := range image {
+		if !isKey(key) {
+			continue
+		}
+
+		version, err := versionFromKey(key)
+		if err != nil {
+			return false
+		}
+		if version < fromVersion {
+			continue
+		}
+
+		av, ok := image[makeCommittedKey(version)]
+		if !ok || av.DataType() != events.DataTypeNumber {
+			return false
+		}
+		committed, err := strconv.ParseInt(av.Number(), 10, 64)
+		if err != nil || committed >= notBefore {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sameBinary(a, b events.DynamoDBAttributeValue) bool {
+	if a.DataType() != events.DataTypeBinary || b.DataType() != events.DataTypeBinary {
+		return a.DataType() == b.DataType()
+	}
+	return bytes.Equal(a.Binary(), b.Binary())
+}
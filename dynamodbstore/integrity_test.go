@@ -0,0 +1,154 @@
+package dynamodbstore
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestCheckIntegrity(t *testing.T) {
+	var (
+		a = events.NewBinaryAttribute([]byte("a"))
+		b = events.NewBinaryAttribute([]byte("b"))
+		x = events.NewBinaryAttribute([]byte("x"))
+	)
+
+	testCases := map[string]struct {
+		EventName   events.DynamoDBOperationType
+		AggregateID string
+		Partition   int
+		OldImage    map[string]events.DynamoDBAttributeValue
+		NewImage    map[string]events.DynamoDBAttributeValue
+		Expected    error
+	}{
+		"append": {
+			EventName:   events.DynamoDBOperationTypeModify,
+			AggregateID: "abc",
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_1": a},
+			NewImage:    map[string]events.DynamoDBAttributeValue{"_1": a, "_2": b},
+		},
+		"modified": {
+			EventName:   events.DynamoDBOperationTypeModify,
+			AggregateID: "abc",
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_1": a, "_2": b},
+			NewImage:    map[string]events.DynamoDBAttributeValue{"_1": x, "_2": b},
+			Expected:    &IntegrityError{AggregateID: "abc", SequenceNumber: "1", Modified: []int{1}},
+		},
+		"attribute removed": {
+			EventName:   events.DynamoDBOperationTypeModify,
+			AggregateID: "abc",
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_1": a, "_2": b},
+			NewImage:    map[string]events.DynamoDBAttributeValue{"_1": a},
+			Expected:    &IntegrityError{AggregateID: "abc", SequenceNumber: "1", Removed: []int{2}},
+		},
+		"item removed": {
+			EventName:   events.DynamoDBOperationTypeRemove,
+			AggregateID: "abc",
+			Partition:   1,
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_100": a, "_101": b},
+			Expected:    &IntegrityError{AggregateID: "abc", Partition: 1, SequenceNumber: "1", Removed: []int{100, 101}},
+		},
+		"item removed without images": {
+			EventName:   events.DynamoDBOperationTypeRemove,
+			AggregateID: "abc",
+			Expected:    &IntegrityError{AggregateID: "abc", SequenceNumber: "1"},
+		},
+		"truncated": {
+			EventName:   events.DynamoDBOperationTypeModify,
+			AggregateID: "abc",
+			Partition:   1,
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_2": a, "_3": b},
+			NewImage:    map[string]events.DynamoDBAttributeValue{"_3": b, truncatedAttribute: events.NewNumberAttribute("3")},
+		},
+		"removed beyond truncation": {
+			EventName:   events.DynamoDBOperationTypeModify,
+			AggregateID: "abc",
+			Partition:   1,
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_2": a, "_3": b},
+			NewImage:    map[string]events.DynamoDBAttributeValue{truncatedAttribute: events.NewNumberAttribute("3")},
+			Expected:    &IntegrityError{AggregateID: "abc", Partition: 1, SequenceNumber: "1", Removed: []int{3}},
+		},
+		"deleted marker": {
+			EventName:   events.DynamoDBOperationTypeModify,
+			AggregateID: "abc",
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_1": a},
+			NewImage:    map[string]events.DynamoDBAttributeValue{deletedAttribute: events.NewBooleanAttribute(true)},
+			Expected:    &IntegrityError{AggregateID: "abc", SequenceNumber: "1", Removed: []int{1}},
+		},
+		"head deleted": {
+			EventName:   events.DynamoDBOperationTypeModify,
+			AggregateID: "abc",
+			Partition:   headPartition,
+			NewImage:    map[string]events.DynamoDBAttributeValue{deletedAttribute: events.NewBooleanAttribute(true)},
+		},
+		"expired item removed": {
+			EventName:   events.DynamoDBOperationTypeRemove,
+			AggregateID: "abc",
+			OldImage: map[string]events.DynamoDBAttributeValue{
+				"_1":                a,
+				ExpiresAttribute:    events.NewNumberAttribute("1"),
+				retainFromAttribute: events.NewNumberAttribute("2"),
+			},
+		},
+		"aged item removed": {
+			EventName:   events.DynamoDBOperationTypeRemove,
+			AggregateID: "abc",
+			OldImage: map[string]events.DynamoDBAttributeValue{
+				"_1":                 a,
+				"@1":                 events.NewNumberAttribute("100"),
+				ExpiresAttribute:     events.NewNumberAttribute("1"),
+				retainFromAttribute:  events.NewNumberAttribute("0"),
+				retainSinceAttribute: events.NewNumberAttribute("200"),
+			},
+		},
+		"expires without window": {
+			EventName:   events.DynamoDBOperationTypeRemove,
+			AggregateID: "abc",
+			OldImage:    map[string]events.DynamoDBAttributeValue{"_1": a, ExpiresAttribute: events.NewNumberAttribute("1")},
+			Expected:    &IntegrityError{AggregateID: "abc", SequenceNumber: "1", Removed: []int{1}},
+		},
+		"retained event removed": {
+			EventName:   events.DynamoDBOperationTypeRemove,
+			AggregateID: "abc",
+			OldImage: map[string]events.DynamoDBAttributeValue{
+				"_1":                 a,
+				"_2":                 b,
+				"@1":                 events.NewNumberAttribute("100"),
+				"@2":                 events.NewNumberAttribute("300"),
+				ExpiresAttribute:     events.NewNumberAttribute("1"),
+				retainFromAttribute:  events.NewNumberAttribute("0"),
+				retainSinceAttribute: events.NewNumberAttribute("200"),
+			},
+			Expected: &IntegrityError{AggregateID: "abc", SequenceNumber: "1", Removed: []int{1, 2}},
+		},
+		"reservation released": {
+			EventName:   events.DynamoDBOperationTypeRemove,
+			AggregateID: reservedPrefix + "reservation/email/a@example.com",
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			change := events.DynamoDBStreamRecord{
+				SequenceNumber: "1",
+				OldImage:       tc.OldImage,
+				NewImage:       tc.NewImage,
+			}
+			err := checkIntegrity(tc.EventName, tc.AggregateID, tc.Partition, change)
+			if tc.Expected == nil {
+				if err != nil {
+					t.Fatalf("got %v; want nil", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(err, tc.Expected) {
+				t.Fatalf("got %v; want %v", err, tc.Expected)
+			}
+			if !eventsource.ErrHasCode(err, ErrIntegrity) {
+				t.Fatalf("got %v; want code %v", err, ErrIntegrity)
+			}
+		})
+	}
+}
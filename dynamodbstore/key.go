@@ -8,12 +8,20 @@ import (
 const (
 	// prefix prefixes the event keys in the dynamodb item
 	prefix = "_"
+
+	// reservedPrefix prefixes hash keys of items that do not belong to an aggregate e.g. reservations
+	reservedPrefix = "$"
 )
 
 func isKey(key string) bool {
 	return strings.HasPrefix(key, prefix)
 }
 
+// isReservedKey returns true if the hash key belongs to an item that is not part of an aggregate
+func isReservedKey(hashKey string) bool {
+	return strings.HasPrefix(hashKey, reservedPrefix)
+}
+
 func makeKey(version int) string {
 	return prefix + strconv.Itoa(version)
 }
@@ -0,0 +1,127 @@
+package dynamodbstore
+
+import (
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// maxTransactItems is the maximum number of items dynamodb accepts in a single transaction
+	maxTransactItems = 100
+
+	// maxTransactSize is the maximum aggregate size, in bytes, of the items in a single transaction
+	maxTransactSize = 4 * 1024 * 1024
+
+	// maxExpressionSize is the maximum length of any one expression
+	maxExpressionSize = 4 * 1024
+
+	// maxItemSize is the maximum size, in bytes, of a single item
+	maxItemSize = 400 * 1024
+)
+
+const (
+	// LimitTransactItems is the limit on the number of items written by a single transaction
+	LimitTransactItems = "TransactItems"
+
+	// LimitTransactSize is the limit on the total bytes written by a single transaction
+	LimitTransactSize = "TransactSize"
+
+	// LimitExpressionSize is the limit on the length of a condition or update expression
+	LimitExpressionSize = "ExpressionSize"
+
+	// LimitItemSize is the limit on the size of a single item
+	LimitItemSize = "ItemSize"
+)
+
+// attributeSize approximates the number of bytes dynamodb will count for the attribute value
+func attributeSize(av *dynamodb.AttributeValue) int {
+	if av == nil {
+		return 0
+	}
+
+	switch {
+	case av.B != nil:
+		return len(av.B)
+	case av.S != nil:
+		return len(*av.S)
+	case av.N != nil:
+		return len(*av.N)
+	}
+
+	size := 0
+	for k, v := range av.M {
+		size += len(k) + attributeSize(v)
+	}
+	for _, v := range av.L {
+		size += attributeSize(v)
+	}
+	return size
+}
+
+// writeSize approximates the number of bytes the write contributes to a transaction
+func writeSize(item *dynamodb.TransactWriteItem) int {
+	var (
+		size   int
+		key    map[string]*dynamodb.AttributeValue
+		names  map[string]*string
+		values map[string]*dynamodb.AttributeValue
+	)
+
+	switch {
+	case item.Update != nil:
+		key, names, values = item.Update.Key, item.Update.ExpressionAttributeNames, item.Update.ExpressionAttributeValues
+	case item.Put != nil:
+		values, names = item.Put.Item, item.Put.ExpressionAttributeNames
+	case item.Delete != nil:
+		key, names, values = item.Delete.Key, item.Delete.ExpressionAttributeNames, item.Delete.ExpressionAttributeValues
+	case item.ConditionCheck != nil:
+		key, names, values = item.ConditionCheck.Key, item.ConditionCheck.ExpressionAttributeNames, item.ConditionCheck.ExpressionAttributeValues
+	}
+
+	for k, v := range key {
+		size += len(k) + attributeSize(v)
+	}
+	for _, v := range names {
+		if v != nil {
+			size += len(*v)
+		}
+	}
+	for k, v := range values {
+		size += len(k) + attributeSize(v)
+	}
+
+	return size
+}
+
+// checkTransactLimits verifies the items fit within a single dynamodb transaction
+func checkTransactLimits(items ...*dynamodb.TransactWriteItem) error {
+	if n := len(items); n > maxTransactItems {
+		return &LimitError{Limit: LimitTransactItems, Size: n, Max: maxTransactItems}
+	}
+
+	size := 0
+	for _, item := range items {
+		size += writeSize(item)
+	}
+	if size > maxTransactSize {
+		return &LimitError{Limit: LimitTransactSize, Size: size, Max: maxTransactSize}
+	}
+
+	return nil
+}
+
+// checkUpdateLimits verifies the update of a single partition item stays within the expression and
+// item limits.  The size of the item already stored is not known so the check covers the bytes being
+// added; an item that is already near the limit may still be rejected by dynamodb.
+func checkUpdateLimits(update *dynamodb.Update) error {
+	for _, expr := range []*string{update.ConditionExpression, update.UpdateExpression} {
+		if expr != nil && len(*expr) > maxExpressionSize {
+			return &LimitError{Limit: LimitExpressionSize, Size: len(*expr), Max: maxExpressionSize}
+		}
+	}
+
+	if size := writeSize(&dynamodb.TransactWriteItem{Update: update}); size > maxItemSize {
+		return &LimitError{Limit: LimitItemSize, Size: size, Max: maxItemSize}
+	}
+
+	return nil
+}
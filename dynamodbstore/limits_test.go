@@ -0,0 +1,160 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"testing/quick"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// payload is shared by the generated records to keep allocations down
+var payload = make([]byte, 64*1024)
+
+func makeBatch(start, count, size int) []eventsource.Record {
+	records := make([]eventsource.Record, 0, count)
+	for i := 0; i < count; i++ {
+		records = append(records, eventsource.Record{Version: start + i, Data: payload[:size]})
+	}
+	return records
+}
+
+// checkWrites verifies the writes produced for the records either name an exceeded limit or stay
+// within every limit while storing each record once in its own partition
+func checkWrites(t *testing.T, eventsPerItem int, records []eventsource.Record) bool {
+	store, err := New("blah", WithDynamoDB(&transactAPI{}), WithEventPerItem(eventsPerItem))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	items, err := store.makeEventWrites("abc", nil, records...)
+	if err == nil {
+		err = checkTransactLimits(items...)
+	}
+	if err != nil {
+		v, ok := err.(*LimitError)
+		if !ok {
+			t.Logf("got %v; want *LimitError", err)
+			return false
+		}
+		if v.Size <= v.Max {
+			t.Logf("got %v within limit %v for %v", v.Size, v.Max, v.Limit)
+			return false
+		}
+		return true
+	}
+
+	seen := map[int]int{}
+	for _, item := range items {
+		update := item.Update
+		if n := len(*update.ConditionExpression); n > maxExpressionSize {
+			t.Logf("got condition of %v bytes; want at most %v", n, maxExpressionSize)
+			return false
+		}
+		if n := len(*update.UpdateExpression); n > maxExpressionSize {
+			t.Logf("got update of %v bytes; want at most %v", n, maxExpressionSize)
+			return false
+		}
+		if n := writeSize(item); n > maxItemSize {
+			t.Logf("got item of %v bytes; want at most %v", n, maxItemSize)
+			return false
+		}
+
+		partition, _ := strconv.Atoi(aws.StringValue(update.Key[RangeKey].N))
+		for _, name := range update.ExpressionAttributeNames {
+			version, err := versionFromKey(aws.StringValue(name))
+			if err != nil {
+				continue
+			}
+			if got := selectPartition(version, eventsPerItem); got != partition {
+				t.Logf("got version %v in partition %v; want %v", version, partition, got)
+				return false
+			}
+			seen[version]++
+		}
+	}
+
+	for _, record := range records {
+		if seen[record.Version] != 1 {
+			t.Logf("got version %v written %v times; want 1", record.Version, seen[record.Version])
+			return false
+		}
+	}
+	return len(seen) == len(records)
+}
+
+func TestMakeEventWrites_BatchSize(t *testing.T) {
+	fn := func(start, count uint16, eventsPerItem uint8) bool {
+		records := makeBatch(int(start)%1000+1, int(count)%300+1, 16)
+		return checkWrites(t, int(eventsPerItem)%200+1, records)
+	}
+	if err := quick.Check(fn, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMakeEventWrites_PayloadSize(t *testing.T) {
+	fn := func(count uint8, size uint16, eventsPerItem uint8) bool {
+		records := makeBatch(1, int(count)%100+1, int(size)%len(payload))
+		return checkWrites(t, int(eventsPerItem)%100+1, records)
+	}
+	if err := quick.Check(fn, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_SaveLimits(t *testing.T) {
+	store, err := New("blah", WithDynamoDB(&transactAPI{}), WithEventPerItem(1000))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	testCases := map[string]struct {
+		Records []eventsource.Record
+		Limit   string
+	}{
+		"expression": {
+			Records: makeBatch(1, 500, 0),
+			Limit:   LimitExpressionSize,
+		},
+		"item": {
+			Records: append(makeBatch(1, 7, len(payload)), makeBatch(8, 1, 0)...),
+			Limit:   LimitItemSize,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			err := store.Save(context.Background(), "abc", tc.Records...)
+			if !eventsource.ErrHasCode(err, ErrLimitExceeded) {
+				t.Fatalf("got %v; want %v", err, ErrLimitExceeded)
+			}
+			if got := err.(*LimitError).Limit; got != tc.Limit {
+				t.Fatalf("got %v; want %v", got, tc.Limit)
+			}
+		})
+	}
+}
+
+func TestStore_SaveAcrossPartitions(t *testing.T) {
+	api := &transactAPI{}
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(10))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := store.Save(context.Background(), "abc", makeBatch(8, 5, 1)...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if got, want := len(api.input.TransactItems), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i, want := range []string{"0", "1"} {
+		if got := *api.input.TransactItems[i].Update.Key[RangeKey].N; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package dynamodbstore
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// memoryTable holds items keyed by hash and range key; supports the reads and simple SET and REMOVE
+// updates used by retention and deletion.  Conditions are not evaluated; tests that depend on them
+// run against dynamodb local, see dynamodbOrSkip.
+type memoryTable struct {
+	dynamodbiface.DynamoDBAPI
+	items   map[string]map[string]*dynamodb.AttributeValue
+	deleted []string
+
+	// batchLimit, if set, returns the keys of a BatchGetItem past the limit unprocessed as when
+	// dynamodb throttles
+	batchLimit int
+	batchGets  int
+}
+
+func tableKey(key map[string]*dynamodb.AttributeValue) string {
+	return *key[HashKey].S + "|" + *key[RangeKey].N
+}
+
+func (m *memoryTable) put(item map[string]*dynamodb.AttributeValue) {
+	if m.items == nil {
+		m.items = map[string]map[string]*dynamodb.AttributeValue{}
+	}
+	m.items[tableKey(item)] = item
+}
+
+// putEvents stores the versions in their partition items, each committed at the time provided
+func (m *memoryTable) putEvents(aggregateID string, eventsPerItem int, committed time.Time, versions ...int) {
+	for _, version := range versions {
+		key := map[string]*dynamodb.AttributeValue{
+			HashKey:  {S: aws.String(aggregateID)},
+			RangeKey: {N: aws.String(strconv.Itoa(selectPartition(version, eventsPerItem)))},
+		}
+		item, ok := m.items[tableKey(key)]
+		if !ok {
+			item = key
+			m.put(item)
+		}
+		item[makeKey(version)] = &dynamodb.AttributeValue{B: []byte(strconv.Itoa(version))}
+		item[makeCommittedKey(version)] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(committed.UnixNano(), 10))}
+	}
+}
+
+func (m *memoryTable) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.items[tableKey(input.Key)]}, nil
+}
+
+func (m *memoryTable) BatchGetItemWithContext(_ aws.Context, input *dynamodb.BatchGetItemInput, _ ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	m.batchGets++
+
+	out := &dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]*dynamodb.AttributeValue{},
+		UnprocessedKeys: map[string]*dynamodb.KeysAndAttributes{},
+	}
+	for tableName, request := range input.RequestItems {
+		keys := request.Keys
+		if m.batchLimit > 0 && len(keys) > m.batchLimit {
+			out.UnprocessedKeys[tableName] = &dynamodb.KeysAndAttributes{Keys: keys[m.batchLimit:]}
+			keys = keys[:m.batchLimit]
+		}
+		for _, key := range keys {
+			if item, ok := m.items[tableKey(key)]; ok {
+				out.Responses[tableName] = append(out.Responses[tableName], item)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ScanWithContext returns the items whose hash key begins with the :idempotency or :outbox value and
+// whose aggregate attribute matches the :aggregate value; the filter used by hard deletes
+func (m *memoryTable) ScanWithContext(_ aws.Context, input *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	var (
+		values = input.ExpressionAttributeValues
+		keys   []string
+	)
+	for key, item := range m.items {
+		hashKey := *item[HashKey].S
+		if !strings.HasPrefix(hashKey, *values[":idempotency"].S) && !strings.HasPrefix(hashKey, *values[":outbox"].S) {
+			continue
+		}
+		if av, ok := item[aggregateAttribute]; !ok || *av.S != *values[":aggregate"].S {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	out := &dynamodb.ScanOutput{}
+	for _, key := range keys {
+		out.Items = append(out.Items, m.items[key])
+	}
+	return out, nil
+}
+
+func (m *memoryTable) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.put(input.Item)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *memoryTable) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	item, ok := m.items[tableKey(input.Key)]
+	if !ok {
+		item = map[string]*dynamodb.AttributeValue{}
+		for k, v := range input.Key {
+			item[k] = v
+		}
+		m.put(item)
+	}
+
+	expr := *input.UpdateExpression
+	if i := strings.Index(expr, "REMOVE "); i >= 0 {
+		for _, ref := range strings.Split(expr[i+len("REMOVE "):], ", ") {
+			delete(item, *input.ExpressionAttributeNames[ref])
+		}
+		expr = strings.TrimSpace(expr[:i])
+	}
+	if i := strings.Index(expr, "SET "); i >= 0 {
+		for _, assignment := range strings.Split(expr[i+len("SET "):], ", ") {
+			parts := strings.Split(assignment, " = ")
+			item[*input.ExpressionAttributeNames[parts[0]]] = input.ExpressionAttributeValues[parts[1]]
+		}
+	}
+
+	out := &dynamodb.UpdateItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllNew {
+		out.Attributes = item
+	}
+	return out, nil
+}
+
+func (m *memoryTable) DeleteItemWithContext(_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	key := tableKey(input.Key)
+	delete(m.items, key)
+	m.deleted = append(m.deleted, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *memoryTable) QueryWithContext(_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	var (
+		aggregateID = *input.ExpressionAttributeValues[":key"].S
+		from        = math.MinInt32
+		to          = -1
+	)
+	if av, ok := input.ExpressionAttributeValues[":from"]; ok {
+		from, _ = strconv.Atoi(*av.N)
+	}
+	if av, ok := input.ExpressionAttributeValues[":to"]; ok {
+		to, _ = strconv.Atoi(*av.N)
+	}
+	if av, ok := input.ExpressionAttributeValues[":position"]; ok {
+		to, _ = strconv.Atoi(*av.N)
+	}
+
+	var items []map[string]*dynamodb.AttributeValue
+	for _, item := range m.items {
+		partition, _ := strconv.Atoi(*item[RangeKey].N)
+		if *item[HashKey].S != aggregateID || partition < from || (to >= 0 && partition > to) {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	reverse := input.ScanIndexForward != nil && !*input.ScanIndexForward
+	sort.Slice(items, func(i, j int) bool {
+		a, _ := strconv.Atoi(*items[i][RangeKey].N)
+		b, _ := strconv.Atoi(*items[j][RangeKey].N)
+		return a < b != reverse
+	})
+
+	if input.ExclusiveStartKey != nil {
+		for i, item := range items {
+			if tableKey(item) == tableKey(input.ExclusiveStartKey) {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	out := &dynamodb.QueryOutput{Items: items}
+	if input.Limit != nil && int64(len(items)) > *input.Limit {
+		out.Items = items[:*input.Limit]
+		last := out.Items[len(out.Items)-1]
+		out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{HashKey: last[HashKey], RangeKey: last[RangeKey]}
+	}
+	return out, nil
+}
@@ -0,0 +1,110 @@
+package dynamodbstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// SaveMulti atomically saves records to multiple aggregates within a single dynamodb transaction;
+// either every record is saved or none are.  Conflicts are reported as a *ConflictError that names
+// each conflicting aggregate, or as ErrAggregateDeleted if one of them has been deleted.
+func (s *Store) SaveMulti(ctx context.Context, batch map[string][]eventsource.Record) error {
+	aggregateIDs := make([]string, 0, len(batch))
+	for aggregateID, records := range batch {
+		if len(records) > 0 {
+			aggregateIDs = append(aggregateIDs, aggregateID)
+		}
+	}
+	if len(aggregateIDs) == 0 {
+		return nil
+	}
+	sort.Strings(aggregateIDs)
+
+	var (
+		items   []*dynamodb.TransactWriteItem
+		owners  []string // owners[i] holds the aggregate id written by items[i]
+		entries []positionEntry
+	)
+	for _, aggregateID := range aggregateIDs {
+		entries = append(entries, positionEntry{aggregateID: aggregateID, records: batch[aggregateID]})
+
+		writes, err := s.makeEventWrites(aggregateID, nil, batch[aggregateID]...)
+		if err != nil {
+			return err
+		}
+		for _, item := range writes {
+			items = append(items, item)
+			owners = append(owners, aggregateID)
+		}
+	}
+
+	if err := checkTransactLimits(items...); err != nil {
+		return err
+	}
+
+	if err := s.writePositioned(ctx, items, entries...); err != nil {
+		if IsConditionalCheckFailed(err) {
+			return s.checkMultiConflict(ctx, batch, aggregateIDs, owners, err)
+		}
+		if v, ok := err.(awserr.Error); ok {
+			return eventsource.NewError(err, "SaveMulti failed. %v [%v]", v.Message(), v.Code())
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkMultiConflict determines which aggregates caused the transaction to be cancelled.  Returns
+// nil if the transaction is a retry of one that previously committed and, like checkConflict,
+// ErrAggregateDeleted if a conflicting aggregate has been deleted.
+func (s *Store) checkMultiConflict(ctx context.Context, batch map[string][]eventsource.Record, aggregateIDs, owners []string, err error) error {
+	var reasons []string
+	if v, ok := err.(awserr.Error); ok {
+		reasons = cancellationReasons(v)
+	}
+
+	var (
+		conflicts []string
+		seen      = map[string]struct{}{}
+	)
+	// reasons also cover any position writes that follow the writes of the aggregates
+	for i, owner := range owners {
+		if len(reasons) >= len(owners) && reasons[i] != awsReasonConditionalCheckFailed {
+			continue
+		}
+		if _, ok := seen[owner]; ok {
+			continue
+		}
+		seen[owner] = struct{}{}
+		conflicts = append(conflicts, owner)
+	}
+
+	// a retry of a committed transaction conflicts on every aggregate
+	if len(conflicts) == len(aggregateIDs) {
+		replay := true
+		for _, aggregateID := range conflicts {
+			if err := s.checkIdempotent(ctx, aggregateID, batch[aggregateID]...); err != nil {
+				replay = false
+				break
+			}
+		}
+		if replay {
+			return nil
+		}
+	}
+
+	if s.deletion {
+		for _, aggregateID := range conflicts {
+			if deleted := s.checkDeleted(ctx, aggregateID); deleted != nil {
+				return deleted
+			}
+		}
+	}
+
+	return &ConflictError{AggregateIDs: conflicts}
+}
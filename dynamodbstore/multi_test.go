@@ -0,0 +1,148 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+type transactAPI struct {
+	dynamodbiface.DynamoDBAPI
+	input *dynamodb.TransactWriteItemsInput
+	err   error
+}
+
+func (t *transactAPI) TransactWriteItemsWithContext(_ aws.Context, input *dynamodb.TransactWriteItemsInput, _ ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	t.input = input
+	return &dynamodb.TransactWriteItemsOutput{}, t.err
+}
+
+func TestStore_SaveMultiTransaction(t *testing.T) {
+	api := &transactAPI{}
+	store, err := New("blah", WithDynamoDB(api))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	err = store.SaveMulti(context.Background(), map[string][]eventsource.Record{
+		"b": {{Version: 1, Data: []byte("b")}},
+		"a": {{Version: 4, Data: []byte("a")}},
+		"c": nil,
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(api.input.TransactItems), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *api.input.TransactItems[0].Update.Key[HashKey].S, "a"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SaveMultiConflict(t *testing.T) {
+	api := &transactAPI{
+		err: awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]", nil),
+	}
+	store, err := New("blah", WithDynamoDB(api))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	err = store.SaveMulti(context.Background(), map[string][]eventsource.Record{
+		"a": {{Version: 1, Data: []byte("a")}},
+		"b": {{Version: 1, Data: []byte("b")}},
+	})
+	v, ok := err.(*ConflictError)
+	if !ok {
+		t.Fatalf("got %v; want *ConflictError", err)
+	}
+	if got, want := v.AggregateIDs, []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SaveMultiLimits(t *testing.T) {
+	store, err := New("blah", WithDynamoDB(&transactAPI{}))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	t.Run("items", func(t *testing.T) {
+		batch := map[string][]eventsource.Record{}
+		for i := 0; i <= maxTransactItems; i++ {
+			batch[strconv.Itoa(i)] = []eventsource.Record{{Version: 1}}
+		}
+		err := store.SaveMulti(context.Background(), batch)
+		if !eventsource.ErrHasCode(err, ErrLimitExceeded) {
+			t.Fatalf("got %v; want %v", err, ErrLimitExceeded)
+		}
+	})
+
+	t.Run("size", func(t *testing.T) {
+		batch := map[string][]eventsource.Record{}
+		for i := 0; i < 11; i++ {
+			batch[strconv.Itoa(i)] = []eventsource.Record{{Version: 1, Data: make([]byte, 380*1024)}}
+		}
+		err := store.SaveMulti(context.Background(), batch)
+		if !eventsource.ErrHasCode(err, ErrLimitExceeded) {
+			t.Fatalf("got %v; want %v", err, ErrLimitExceeded)
+		}
+	})
+}
+
+func TestStore_SaveMulti(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		batch := map[string][]eventsource.Record{
+			"from": {{Version: 1, Data: []byte("debit")}},
+			"to":   {{Version: 1, Data: []byte("credit")}},
+		}
+		err = store.SaveMulti(ctx, batch)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// retry of the committed transaction
+		err = store.SaveMulti(ctx, batch)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// conflict on a single aggregate saves nothing
+		err = store.SaveMulti(ctx, map[string][]eventsource.Record{
+			"from":  {{Version: 1, Data: []byte("debit-again")}},
+			"other": {{Version: 1, Data: []byte("credit")}},
+		})
+		if v, ok := err.(*ConflictError); !ok || !reflect.DeepEqual(v.AggregateIDs, []string{"from"}) {
+			t.Fatalf("got %v; want conflict on from", err)
+		}
+
+		found, err := store.Load(ctx, "other", 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(found), 0; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package dynamodbstore
 
 import (
 	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
@@ -37,3 +38,117 @@ func WithDebug(w io.Writer) Option {
 		s.writer = w
 	}
 }
+
+// WithStrictVersions requires that the records passed to Save have contiguous versions and that the
+// first record immediately follows the current version of the aggregate.  The current version is
+// tracked by a head item that is updated in the same transaction as the events.  Strict versioning
+// should be enabled before the first event of an aggregate is saved.
+func WithStrictVersions(enabled bool) Option {
+	return func(s *Store) {
+		s.strict = enabled
+	}
+}
+
+// WithDeletion allows aggregates to be closed with Delete and their history shortened with
+// TruncateBefore.  Every save then checks the deletion and truncation markers held by the head item
+// of the aggregate so saves that would otherwise be a single UpdateItem become a transaction, costing
+// twice the write capacity.
+func WithDeletion(enabled bool) Option {
+	return func(s *Store) {
+		s.deletion = enabled
+	}
+}
+
+// WithUpcasters rewrites events saved with historic schemas into their current schema as they are
+// loaded.  The events stored in dynamodb are never modified.
+func WithUpcasters(upcasters *Upcasters) Option {
+	return func(s *Store) {
+		s.upcasters = upcasters
+	}
+}
+
+// WithLoadConcurrency allows Load to query up to n ranges of partitions concurrently when the range
+// of partitions holding the requested events is known, either because toVersion was provided or
+// because strict versioning is enabled.  Defaults to 1, a single serial query; keep n modest on
+// provisioned tables to avoid throttling.
+func WithLoadConcurrency(n int) Option {
+	return func(s *Store) {
+		s.concurrency = n
+	}
+}
+
+// WithRetention hides events outside the retention policy of each aggregate from Load and LoadWith.
+// Enabling retention costs an additional read per load, and a second for aggregates with a MaxCount
+// unless strict versioning is enabled.  See StreamMetadata.
+func WithRetention(enabled bool) Option {
+	return func(s *Store) {
+		s.retention = enabled
+	}
+}
+
+// WithCommitTimes records the commit time of each event saved alongside it, as needed by LoadAsOf and
+// by retention policies with a MaxAge.  Implied by WithRetention.
+func WithCommitTimes(enabled bool) Option {
+	return func(s *Store) {
+		s.commitTimes = enabled
+	}
+}
+
+// WithGlobalPosition assigns each event saved a position in a total order of events across
+// aggregates, see ReadAll.  Positions are allocated from a single counter item within the save
+// transaction so every save becomes a transaction and saves contend for the counter; expect lower
+// write throughput.  Enable before saving the first event that ReadAll should return.
+func WithGlobalPosition(enabled bool) Option {
+	return func(s *Store) {
+		s.positions = enabled
+	}
+}
+
+// WithClock specifies the clock used to timestamp the events saved and to evaluate retention
+// policies; defaults to time.Now
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+type saveOptions struct {
+	idempotencyKey string
+	reservations   []reservation
+	outbox         []OutboxEntry
+}
+
+// SaveOption represents a functional configuration of a single call to SaveWith
+type SaveOption func(*saveOptions)
+
+// WithIdempotencyKey stores the key, typically a command id, alongside the records.  A retried save
+// with the same key and records succeeds without reloading the aggregate while reusing the key
+// with different records fails with ErrIdempotencyConflict.  Each key is retained in an item of its
+// own, keyed by the aggregate and the key, so keys are unique per aggregate.
+func WithIdempotencyKey(key string) SaveOption {
+	return func(o *saveOptions) {
+		o.idempotencyKey = key
+	}
+}
+
+type loadOptions struct {
+	eventual  bool
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// timed returns true if events are filtered by their commit time
+func (o loadOptions) timed() bool {
+	return !o.notBefore.IsZero() || !o.notAfter.IsZero()
+}
+
+// LoadOption represents a functional configuration of a single call to LoadWith
+type LoadOption func(*loadOptions)
+
+// WithEventualConsistency reads with eventual rather than strong consistency; half the cost but events
+// saved in the last second or so may be missing.  Suitable for read models that tolerate lag.
+func WithEventualConsistency() LoadOption {
+	return func(o *loadOptions) {
+		o.eventual = true
+	}
+}
@@ -0,0 +1,135 @@
+package dynamodbstore
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// outboxPrefix prefixes the hash key of outbox items to keep them apart from aggregates
+	outboxPrefix = reservedPrefix + "outbox/"
+
+	// pendingAttribute is present on outbox items that have yet to be dispatched
+	pendingAttribute = "pending"
+
+	outboxAggregateAttribute = "aggregate"
+	outboxVersionAttribute   = "version"
+	outboxTypeAttribute      = "type"
+	outboxDataAttribute      = "data"
+)
+
+// OutboxEntry represents a side effect, such as an email or webhook, to be delivered once the
+// records saved alongside it have been committed
+type OutboxEntry struct {
+	// Type allows the OutboxSender to route the entry
+	Type string
+
+	// Data contains the entry in serialized form
+	Data []byte
+}
+
+// OutboxMessage is an OutboxEntry as read back from the outbox
+type OutboxMessage struct {
+	OutboxEntry
+
+	// ID uniquely and stably identifies the message; suitable for deduplication
+	ID string
+
+	// AggregateID holds the id of the aggregate whose save wrote the entry
+	AggregateID string
+
+	// Version holds the version of the first record saved with the entry
+	Version int
+}
+
+// WithOutbox writes the entries to the outbox in the same transaction as the records.  Use
+// a Dispatcher to deliver them.
+func WithOutbox(entries ...OutboxEntry) SaveOption {
+	return func(o *saveOptions) {
+		o.outbox = append(o.outbox, entries...)
+	}
+}
+
+// makeOutboxID returns the id of the index-th entry saved with version
+func makeOutboxID(aggregateID string, version, index int) string {
+	return aggregateID + "/" + strconv.Itoa(version) + "/" + strconv.Itoa(index)
+}
+
+// makeOutboxWrite writes the pending entry.  No condition is required as the write only commits
+// alongside new records.
+func makeOutboxWrite(tableName, hashKey, rangeKey, aggregateID string, version, index int, entry OutboxEntry) *dynamodb.TransactWriteItem {
+	item := map[string]*dynamodb.AttributeValue{
+		hashKey:                  {S: aws.String(outboxPrefix + makeOutboxID(aggregateID, version, index))},
+		rangeKey:                 {N: aws.String("0")},
+		outboxAggregateAttribute: {S: aws.String(aggregateID)},
+		outboxVersionAttribute:   {N: aws.String(strconv.Itoa(version))},
+		outboxDataAttribute:      {B: entry.Data},
+		pendingAttribute:         {N: aws.String("1")},
+	}
+	if entry.Type != "" {
+		item[outboxTypeAttribute] = &dynamodb.AttributeValue{S: aws.String(entry.Type)}
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: aws.String(tableName),
+			Item:      item,
+		},
+	}
+}
+
+// outboxMessageFromItem decodes an outbox item read via Query or Scan
+func outboxMessageFromItem(hashKey string, item map[string]*dynamodb.AttributeValue) (OutboxMessage, bool) {
+	key, ok := item[hashKey]
+	if !ok || key.S == nil || !strings.HasPrefix(*key.S, outboxPrefix) {
+		return OutboxMessage{}, false
+	}
+
+	message := OutboxMessage{
+		ID: strings.TrimPrefix(*key.S, outboxPrefix),
+	}
+	if av, ok := item[outboxAggregateAttribute]; ok && av.S != nil {
+		message.AggregateID = *av.S
+	}
+	if av, ok := item[outboxVersionAttribute]; ok && av.N != nil {
+		message.Version, _ = strconv.Atoi(*av.N)
+	}
+	if av, ok := item[outboxTypeAttribute]; ok && av.S != nil {
+		message.Type = *av.S
+	}
+	if av, ok := item[outboxDataAttribute]; ok {
+		message.Data = av.B
+	}
+
+	return message, true
+}
+
+// outboxMessageFromImage decodes an outbox item read from a dynamodb stream
+func outboxMessageFromImage(hashKey string, image map[string]events.DynamoDBAttributeValue) (OutboxMessage, bool) {
+	key, ok := image[hashKey]
+	if !ok || key.DataType() != events.DataTypeString || !strings.HasPrefix(key.String(), outboxPrefix) {
+		return OutboxMessage{}, false
+	}
+
+	message := OutboxMessage{
+		ID: strings.TrimPrefix(key.String(), outboxPrefix),
+	}
+	if av, ok := image[outboxAggregateAttribute]; ok && av.DataType() == events.DataTypeString {
+		message.AggregateID = av.String()
+	}
+	if av, ok := image[outboxVersionAttribute]; ok && av.DataType() == events.DataTypeNumber {
+		message.Version, _ = strconv.Atoi(av.Number())
+	}
+	if av, ok := image[outboxTypeAttribute]; ok && av.DataType() == events.DataTypeString {
+		message.Type = av.String()
+	}
+	if av, ok := image[outboxDataAttribute]; ok && av.DataType() == events.DataTypeBinary {
+		message.Data = av.Binary()
+	}
+
+	return message, true
+}
@@ -0,0 +1,191 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+type outboxAPI struct {
+	dynamodbiface.DynamoDBAPI
+	items   []map[string]*dynamodb.AttributeValue
+	deletes []*dynamodb.DeleteItemInput
+}
+
+func (o *outboxAPI) ScanWithContext(_ aws.Context, _ *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{Items: o.items}, nil
+}
+
+func (o *outboxAPI) DeleteItemWithContext(_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	o.deletes = append(o.deletes, input)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestOutboxMessageFromItem(t *testing.T) {
+	entry := OutboxEntry{Type: "email", Data: []byte("hello")}
+	write := makeOutboxWrite("table", HashKey, RangeKey, "abc", 3, 1, entry)
+
+	message, ok := outboxMessageFromItem(HashKey, write.Put.Item)
+	if !ok {
+		t.Fatalf("got false; want true")
+	}
+	want := OutboxMessage{
+		OutboxEntry: entry,
+		ID:          "abc/3/1",
+		AggregateID: "abc",
+		Version:     3,
+	}
+	if got := message; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if _, ok := outboxMessageFromItem(HashKey, map[string]*dynamodb.AttributeValue{HashKey: {S: aws.String("abc")}}); ok {
+		t.Fatalf("got true; want false")
+	}
+}
+
+func TestDispatcher_HandleRecord(t *testing.T) {
+	api := &outboxAPI{}
+	store, err := New("blah", WithDynamoDB(api))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	var sent []OutboxMessage
+	dispatcher := NewDispatcher(store, OutboxSenderFunc(func(ctx context.Context, message OutboxMessage) error {
+		sent = append(sent, message)
+		return nil
+	}))
+
+	record := events.DynamoDBStreamRecord{
+		NewImage: map[string]events.DynamoDBAttributeValue{
+			HashKey:                  events.NewStringAttribute(outboxPrefix + "abc/1/0"),
+			RangeKey:                 events.NewNumberAttribute("0"),
+			outboxAggregateAttribute: events.NewStringAttribute("abc"),
+			outboxVersionAttribute:   events.NewNumberAttribute("1"),
+			outboxDataAttribute:      events.NewBinaryAttribute([]byte("hello")),
+			pendingAttribute:         events.NewNumberAttribute("1"),
+		},
+	}
+	if err := dispatcher.HandleRecord(context.Background(), record); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// events are not outbox messages
+	event := events.DynamoDBStreamRecord{
+		NewImage: map[string]events.DynamoDBAttributeValue{
+			HashKey: events.NewStringAttribute("abc"),
+			"_1":    events.NewBinaryAttribute([]byte("a")),
+		},
+	}
+	if err := dispatcher.HandleRecord(context.Background(), event); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if got, want := len(sent), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := sent[0].ID, "abc/1/0"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := len(api.deletes), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *api.deletes[0].Key[HashKey].S, outboxPrefix+"abc/1/0"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestDispatcher_Poll(t *testing.T) {
+	api := &outboxAPI{
+		items: []map[string]*dynamodb.AttributeValue{
+			makeOutboxWrite("blah", HashKey, RangeKey, "abc", 1, 0, OutboxEntry{Data: []byte("a")}).Put.Item,
+			makeOutboxWrite("blah", HashKey, RangeKey, "abc", 1, 1, OutboxEntry{Data: []byte("b")}).Put.Item,
+		},
+	}
+	store, err := New("blah", WithDynamoDB(api))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	dispatcher := NewDispatcher(store, OutboxSenderFunc(func(ctx context.Context, message OutboxMessage) error {
+		return nil
+	}))
+	n, err := dispatcher.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := n, 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := len(api.deletes), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SaveOutbox(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		records := []eventsource.Record{{Version: 1, Data: []byte("a")}}
+		err = store.SaveWith(ctx, "abc", records, WithOutbox(OutboxEntry{Type: "email", Data: []byte("welcome")}))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		var sent []OutboxMessage
+		dispatcher := NewDispatcher(store, OutboxSenderFunc(func(ctx context.Context, message OutboxMessage) error {
+			sent = append(sent, message)
+			return nil
+		}))
+
+		n, err := dispatcher.Poll(ctx)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := n, 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		// dispatched messages are removed from the outbox
+		n, err = dispatcher.Poll(ctx)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := n, 0; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		out, err := api.GetItem(&dynamodb.GetItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				HashKey:  {S: aws.String(outboxPrefix + sent[0].ID)},
+				RangeKey: {N: aws.String("0")},
+			},
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if len(out.Item) != 0 {
+			t.Fatalf("got %v; want no item", out.Item)
+		}
+	})
+}
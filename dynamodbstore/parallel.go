@@ -0,0 +1,97 @@
+package dynamodbstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// partitionRange holds an inclusive range of partitions
+type partitionRange struct {
+	from, to int
+}
+
+// splitPartitions divides the partitions from through to into at most n contiguous ranges of
+// near equal size
+func splitPartitions(from, to, n int) []partitionRange {
+	total := to - from + 1
+	if n > total {
+		n = total
+	}
+
+	ranges := make([]partitionRange, 0, n)
+	for i := 0; i < n; i++ {
+		size := total / n
+		if i < total%n {
+			size++
+		}
+		ranges = append(ranges, partitionRange{from: from, to: from + size - 1})
+		from += size
+	}
+
+	return ranges
+}
+
+// loadParallel queries the partitions from through to using up to s.concurrency concurrent queries
+// and merges the results in version order.  The last range is left unbounded when toVersion is not
+// known so events saved beyond the head that was read are still returned.
+func (s *Store) loadParallel(ctx context.Context, aggregateID string, fromVersion, toVersion, from, to int, options loadOptions) (eventsource.History, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		ranges  = splitPartitions(from, to, s.concurrency)
+		results = make([]eventsource.History, len(ranges))
+		wg      sync.WaitGroup
+		once    sync.Once
+		failure error
+	)
+
+	for i, r := range ranges {
+		last := r.to
+		if toVersion <= 0 && i == len(ranges)-1 {
+			last = -1
+		}
+
+		input, err := makeQueryInput(s.tableName, s.hashKey, s.rangeKey, aggregateID, r.from, last)
+		if err != nil {
+			return nil, err
+		}
+		input.ConsistentRead = aws.Bool(!options.eventual)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			history, err := s.query(ctx, input, fromVersion, toVersion, options)
+			if err != nil {
+				// report the error that caused the cancellation rather than those it caused
+				once.Do(func() {
+					failure = err
+					cancel()
+				})
+				return
+			}
+			results[i] = history
+		}(i)
+	}
+	wg.Wait()
+
+	if failure != nil {
+		return nil, failure
+	}
+
+	var history eventsource.History
+	for _, result := range results {
+		history = append(history, result...)
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version < history[j].Version
+	})
+
+	return history, nil
+}
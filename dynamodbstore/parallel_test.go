@@ -0,0 +1,180 @@
+package dynamodbstore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// partitionAPI serves queries from items keyed by partition, honoring the partition range of the key
+// condition, and tracks the number of queries in flight
+type partitionAPI struct {
+	dynamodbiface.DynamoDBAPI
+	partitions map[int]map[string]*dynamodb.AttributeValue
+	head       int
+	fail       bool
+
+	mutex    sync.Mutex
+	queries  []partitionRange
+	inFlight int
+	maxIn    int
+}
+
+func (p *partitionAPI) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	from, _ := strconv.Atoi(*input.ExpressionAttributeValues[":from"].N)
+	to := -1
+	if av, ok := input.ExpressionAttributeValues[":to"]; ok {
+		to, _ = strconv.Atoi(*av.N)
+	}
+
+	p.mutex.Lock()
+	p.queries = append(p.queries, partitionRange{from: from, to: to})
+	p.inFlight++
+	if p.inFlight > p.maxIn {
+		p.maxIn = p.inFlight
+	}
+	p.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	p.mutex.Lock()
+	p.inFlight--
+	p.mutex.Unlock()
+
+	if p.fail && from > 0 {
+		return nil, errors.New("boom")
+	}
+
+	out := &dynamodb.QueryOutput{}
+	for partition := from; to < 0 || partition <= to; partition++ {
+		item, ok := p.partitions[partition]
+		if !ok {
+			break
+		}
+		out.Items = append(out.Items, item)
+	}
+	return out, nil
+}
+
+func (p *partitionAPI) GetItemWithContext(_ aws.Context, _ *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: map[string]*dynamodb.AttributeValue{
+		headAttribute: {N: aws.String(strconv.Itoa(p.head))},
+	}}, nil
+}
+
+// newPartitionAPI returns n events stored two to a partition
+func newPartitionAPI(n int) *partitionAPI {
+	api := &partitionAPI{partitions: map[int]map[string]*dynamodb.AttributeValue{}, head: n}
+	for version := 1; version <= n; version++ {
+		partition := selectPartition(version, 2)
+		if api.partitions[partition] == nil {
+			api.partitions[partition] = map[string]*dynamodb.AttributeValue{}
+		}
+		api.partitions[partition][makeKey(version)] = &dynamodb.AttributeValue{B: []byte{byte(version)}}
+	}
+	return api
+}
+
+func TestSplitPartitions(t *testing.T) {
+	testCases := map[string]struct {
+		From, To, N int
+		Expected    []partitionRange
+	}{
+		"even":    {From: 0, To: 5, N: 3, Expected: []partitionRange{{0, 1}, {2, 3}, {4, 5}}},
+		"uneven":  {From: 1, To: 5, N: 2, Expected: []partitionRange{{1, 3}, {4, 5}}},
+		"too few": {From: 3, To: 4, N: 8, Expected: []partitionRange{{3, 3}, {4, 4}}},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if got := splitPartitions(tc.From, tc.To, tc.N); !reflect.DeepEqual(got, tc.Expected) {
+				t.Fatalf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestStore_LoadParallel(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("bounded", func(t *testing.T) {
+		api := newPartitionAPI(20)
+		s := &Store{api: api, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey, eventsPerItem: 2, concurrency: 3}
+
+		history, err := s.Load(ctx, "abc", 3, 17)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := versionsOf(history), versionRange(3, 17); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := len(api.queries), 3; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := api.maxIn, 3; got != want {
+			t.Fatalf("got %v in flight; want %v", got, want)
+		}
+	})
+
+	t.Run("head", func(t *testing.T) {
+		api := newPartitionAPI(20)
+		api.head = 15 // events saved after the head was read are still returned
+		s := &Store{api: api, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey, eventsPerItem: 2, concurrency: 2, strict: true}
+
+		history, err := s.Load(ctx, "abc", 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := versionsOf(history), versionRange(1, 20); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		unbounded := 0
+		for _, r := range api.queries {
+			if r.to < 0 {
+				unbounded++
+			}
+		}
+		if got, want := unbounded, 1; got != want {
+			t.Fatalf("got %v unbounded ranges in %v; want %v", got, api.queries, want)
+		}
+	})
+
+	t.Run("unknown range", func(t *testing.T) {
+		api := newPartitionAPI(20)
+		s := &Store{api: api, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey, eventsPerItem: 2, concurrency: 4}
+
+		if _, err := s.Load(ctx, "abc", 0, 0); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(api.queries), 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		api := newPartitionAPI(20)
+		api.fail = true
+		s := &Store{api: api, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey, eventsPerItem: 2, concurrency: 4}
+
+		if _, err := s.Load(ctx, "abc", 0, 20); err == nil || err.Error() != "boom" {
+			t.Fatalf("got %v; want boom", err)
+		}
+	})
+}
+
+func versionRange(from, to int) []int {
+	var versions []int
+	for version := from; version <= to; version++ {
+		versions = append(versions, version)
+	}
+	return versions
+}
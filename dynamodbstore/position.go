@@ -0,0 +1,494 @@
+package dynamodbstore
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// positionKey is the hash key of the item holding the most recently assigned global position
+	positionKey = reservedPrefix + "position"
+
+	// positionIndexPrefix prefixes the hash key of the position index items.  Index items are
+	// grouped into buckets of positionBucketSize positions and keyed by the first position of the
+	// save they index.  A save that runs past the end of its bucket also writes a continuation item
+	// at the first position of each later bucket it reaches.
+	positionIndexPrefix = reservedPrefix + "position/"
+
+	// positionBucketSize is the number of positions held by each bucket of the position index
+	positionBucketSize = 1000
+
+	// positionAttribute holds the most recently assigned global position
+	positionAttribute = "position"
+
+	// aggregateAttribute holds the aggregate id of the records indexed
+	aggregateAttribute = "aggregate"
+
+	// versionsAttribute holds, in position order, the versions of the records indexed
+	versionsAttribute = "versions"
+
+	// continuesAttribute marks a continuation item and holds the first position of the save that
+	// continues into the bucket
+	continuesAttribute = "continues"
+
+	// maxPositionAttempts is the number of times Save allocates a global position before giving up
+	// when other saves contend for the position
+	maxPositionAttempts = 10
+
+	// positionBackoffBase and positionBackoffMax bound the delay before Save allocates a contended
+	// global position again
+	positionBackoffBase = 10 * time.Millisecond
+	positionBackoffMax  = time.Second
+
+	// maxBatchGetKeys is the number of keys dynamodb accepts in a single BatchGetItem
+	maxBatchGetKeys = 100
+)
+
+var (
+	// jitterRand is seeded independently of math/rand so processes contending for the global
+	// position do not back off in lockstep
+	jitterMutex sync.Mutex
+	jitterRand  = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// positionBackoff returns a random delay up to an exponential backoff of the attempt, spreading the
+// retries of saves that contended for the same position
+func positionBackoff(attempt int) time.Duration {
+	delay := ExponentialBackoff(positionBackoffBase, positionBackoffMax)(attempt)
+
+	jitterMutex.Lock()
+	defer jitterMutex.Unlock()
+	return time.Duration(jitterRand.Int63n(int64(delay) + 1))
+}
+
+// GlobalRecord is an event along with its position in the total order of events across aggregates
+type GlobalRecord struct {
+	// Position holds the global position of the event; positions start at 1
+	Position int64
+
+	// AggregateID holds the id of the aggregate the event belongs to
+	AggregateID string
+
+	// Record holds the event
+	Record eventsource.Record
+}
+
+// positionEntry holds the records of a single aggregate to be assigned global positions
+type positionEntry struct {
+	aggregateID string
+	records     []eventsource.Record
+}
+
+func positionBucket(position int64) int64 {
+	return position / positionBucketSize
+}
+
+func makePositionIndexKey(bucket int64) string {
+	return positionIndexPrefix + strconv.FormatInt(bucket, 10)
+}
+
+// loadPosition returns the most recently assigned global position; 0 if none has been assigned
+func (s *Store) loadPosition(ctx context.Context) (int64, error) {
+	out, err := s.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			s.hashKey:  {S: aws.String(positionKey)},
+			s.rangeKey: {N: aws.String("0")},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	av, ok := out.Item[positionAttribute]
+	if !ok || av.N == nil {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(*av.N, 10, 64)
+}
+
+// makePositionIndexItem returns the key of the position index item at position
+func (s *Store) makePositionIndexItem(position int64) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		s.hashKey:  {S: aws.String(makePositionIndexKey(positionBucket(position)))},
+		s.rangeKey: {N: aws.String(strconv.FormatInt(position, 10))},
+	}
+}
+
+// makePositionWrites returns the writes that advance the global position from current past the
+// records of each entry, followed by the writes that index each entry at its first position along
+// with a continuation item in each later bucket the entry reaches.  The advance is conditional on
+// the position still being current.
+func (s *Store) makePositionWrites(current int64, entries ...positionEntry) []*dynamodb.TransactWriteItem {
+	var n int64
+	for _, entry := range entries {
+		n += int64(len(entry.records))
+	}
+
+	advance := &dynamodb.Update{
+		TableName: aws.String(s.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			s.hashKey:  {S: aws.String(positionKey)},
+			s.rangeKey: {N: aws.String("0")},
+		},
+		UpdateExpression: aws.String("SET #position = :next"),
+		ExpressionAttributeNames: map[string]*string{
+			"#position": aws.String(positionAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":next": {N: aws.String(strconv.FormatInt(current+n, 10))},
+		},
+	}
+	if current == 0 {
+		advance.ConditionExpression = aws.String("attribute_not_exists(#position)")
+	} else {
+		advance.ConditionExpression = aws.String("#position = :current")
+		advance.ExpressionAttributeValues[":current"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(current, 10))}
+	}
+
+	items := []*dynamodb.TransactWriteItem{{Update: advance}}
+
+	position := current + 1
+	for _, entry := range entries {
+		versions := make([]*dynamodb.AttributeValue, 0, len(entry.records))
+		for _, record := range entry.records {
+			versions = append(versions, &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(record.Version))})
+		}
+
+		item := s.makePositionIndexItem(position)
+		item[aggregateAttribute] = &dynamodb.AttributeValue{S: aws.String(entry.aggregateID)}
+		item[versionsAttribute] = &dynamodb.AttributeValue{L: versions}
+		items = append(items, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{TableName: aws.String(s.tableName), Item: item},
+		})
+
+		last := position + int64(len(entry.records)) - 1
+		for bucket := positionBucket(position) + 1; bucket <= positionBucket(last); bucket++ {
+			continuation := s.makePositionIndexItem(bucket * positionBucketSize)
+			continuation[continuesAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(position, 10))}
+			items = append(items, &dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{TableName: aws.String(s.tableName), Item: continuation},
+			})
+		}
+
+		position = last + 1
+	}
+
+	return items
+}
+
+// writePositioned applies the items, along with the writes that assign global positions to the
+// entries when enabled, as a single atomic write.  Positions are allocated optimistically; the write
+// is retried after a jittered backoff when another save claims the position first.
+func (s *Store) writePositioned(ctx context.Context, items []*dynamodb.TransactWriteItem, entries ...positionEntry) error {
+	if !s.positions {
+		return s.writeItems(ctx, items...)
+	}
+
+	var (
+		current int64
+		err     error
+	)
+	for attempt := 0; attempt < maxPositionAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(positionBackoff(attempt)):
+			}
+		}
+
+		if current, err = s.loadPosition(ctx); err != nil {
+			return err
+		}
+
+		positioned := append(items[:len(items):len(items)], s.makePositionWrites(current, entries...)...)
+		if err := checkTransactLimits(positioned...); err != nil {
+			return err
+		}
+
+		err = s.writeItems(ctx, positioned...)
+		if err == nil || !positionContended(err, len(items)) {
+			return err
+		}
+	}
+
+	return eventsource.NewError(err, ErrPositionContention, "unable to allocate a global position after %v attempts", maxPositionAttempts)
+}
+
+// positionContended returns true if the transaction was cancelled only because of the global position
+// at index; either it changed after it was read or another save was assigning positions at the same
+// time
+func positionContended(err error, index int) bool {
+	v, ok := err.(awserr.Error)
+	if !ok || v.Code() != dynamodb.ErrCodeTransactionCanceledException {
+		return false
+	}
+
+	reasons := cancellationReasons(v)
+	if index >= len(reasons) {
+		return false
+	}
+	if reason := reasons[index]; reason != awsReasonConditionalCheckFailed && reason != awsReasonTransactionConflict {
+		return false
+	}
+	for i, reason := range reasons {
+		if i != index && reason != "None" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// positionFloor returns the first position of the save that includes position; position itself if
+// no indexed save does.  Only the bucket of position is read as a save that began in an earlier
+// bucket leaves a continuation item at the start of it.
+func (s *Store) positionFloor(ctx context.Context, position int64) (int64, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.tableName),
+		KeyConditionExpression: aws.String("#key = :key AND #partition <= :position"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":       aws.String(s.hashKey),
+			"#partition": aws.String(s.rangeKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":key":      {S: aws.String(makePositionIndexKey(positionBucket(position)))},
+			":position": {N: aws.String(strconv.FormatInt(position, 10))},
+		},
+		ConsistentRead:   aws.Bool(true),
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(1),
+	}
+
+	out, err := s.api.QueryWithContext(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+	if len(out.Items) == 0 {
+		return position, nil
+	}
+
+	item := out.Items[0]
+	if av, ok := item[continuesAttribute]; ok {
+		return strconv.ParseInt(aws.StringValue(av.N), 10, 64)
+	}
+	return strconv.ParseInt(aws.StringValue(item[s.rangeKey].N), 10, 64)
+}
+
+// ReadAll calls fn with each event at or after fromPosition in global order.  Consumers may record
+// the position of the last event handled and resume from the following position.  Requires
+// WithGlobalPosition; events saved before global positions were enabled are not returned.  ReadAll
+// returns once the most recent event has been read or fn returns an error.
+func (s *Store) ReadAll(ctx context.Context, fromPosition int64, fn func(GlobalRecord) error) error {
+	if fromPosition < 1 {
+		fromPosition = 1
+	}
+
+	current, err := s.loadPosition(ctx)
+	if err != nil || fromPosition > current {
+		return err
+	}
+
+	floor, err := s.positionFloor(ctx, fromPosition)
+	if err != nil {
+		return err
+	}
+
+	for bucket := positionBucket(floor); bucket <= positionBucket(current); bucket++ {
+		input := &dynamodb.QueryInput{
+			TableName:              aws.String(s.tableName),
+			KeyConditionExpression: aws.String("#key = :key AND #partition >= :from"),
+			ExpressionAttributeNames: map[string]*string{
+				"#key":       aws.String(s.hashKey),
+				"#partition": aws.String(s.rangeKey),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":key":  {S: aws.String(makePositionIndexKey(bucket))},
+				":from": {N: aws.String(strconv.FormatInt(floor, 10))},
+			},
+			ConsistentRead: aws.Bool(true),
+		}
+
+		for {
+			out, err := s.api.QueryWithContext(ctx, input)
+			if err != nil {
+				return err
+			}
+
+			if err := s.readEntries(ctx, out.Items, fromPosition, fn); err != nil {
+				return err
+			}
+
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+			input.ExclusiveStartKey = out.LastEvaluatedKey
+		}
+	}
+
+	return nil
+}
+
+// indexEntry holds the positions of the records indexed by a single position index item
+type indexEntry struct {
+	aggregateID string
+	versions    []int
+	positions   []int64
+}
+
+// parseIndexEntry returns the records of the index item at or after fromPosition, in position order
+func (s *Store) parseIndexEntry(item map[string]*dynamodb.AttributeValue, fromPosition int64) (indexEntry, error) {
+	entry := indexEntry{aggregateID: aws.StringValue(item[aggregateAttribute].S)}
+
+	first, err := strconv.ParseInt(aws.StringValue(item[s.rangeKey].N), 10, 64)
+	if err != nil {
+		return entry, err
+	}
+
+	for i, av := range item[versionsAttribute].L {
+		version, err := strconv.Atoi(aws.StringValue(av.N))
+		if err != nil {
+			return entry, err
+		}
+		if position := first + int64(i); position >= fromPosition {
+			entry.versions = append(entry.versions, version)
+			entry.positions = append(entry.positions, position)
+		}
+	}
+
+	return entry, nil
+}
+
+func makePartitionRef(aggregateID string, partition int) string {
+	return aggregateID + "/" + strconv.Itoa(partition)
+}
+
+// batchGetPartitions reads the partition items of each aggregate given, keyed by makePartitionRef,
+// using as few BatchGetItem calls as dynamodb allows
+func (s *Store) batchGetPartitions(ctx context.Context, refs map[string][]int) (map[string]map[string]*dynamodb.AttributeValue, error) {
+	var keys []map[string]*dynamodb.AttributeValue
+	for aggregateID, partitions := range refs {
+		for _, partition := range partitions {
+			keys = append(keys, s.makePartitionKey(aggregateID, partition))
+		}
+	}
+
+	items := map[string]map[string]*dynamodb.AttributeValue{}
+	for attempt := 0; len(keys) > 0; {
+		n := len(keys)
+		if n > maxBatchGetKeys {
+			n = maxBatchGetKeys
+		}
+
+		out, err := s.api.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{
+				s.tableName: {Keys: keys[:n], ConsistentRead: aws.Bool(true)},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range out.Responses[s.tableName] {
+			partition, err := strconv.Atoi(aws.StringValue(item[s.rangeKey].N))
+			if err != nil {
+				return nil, err
+			}
+			items[makePartitionRef(aws.StringValue(item[s.hashKey].S), partition)] = item
+		}
+
+		keys = keys[n:]
+
+		// keys left unprocessed when dynamodb throttles are read again after a backoff
+		unprocessed, ok := out.UnprocessedKeys[s.tableName]
+		if !ok || len(unprocessed.Keys) == 0 {
+			continue
+		}
+		keys = append(unprocessed.Keys, keys...)
+
+		attempt++
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(positionBackoff(attempt)):
+		}
+	}
+
+	return items, nil
+}
+
+// readEntries loads the records of a page of position index items and calls fn with those at or
+// after fromPosition in position order.  The partitions of every aggregate in the page are read
+// together.  Continuation items and records since removed from their aggregate are skipped.
+func (s *Store) readEntries(ctx context.Context, items []map[string]*dynamodb.AttributeValue, fromPosition int64, fn func(GlobalRecord) error) error {
+	var (
+		entries = make([]indexEntry, 0, len(items))
+		refs    = map[string][]int{}
+		seen    = map[string]bool{}
+	)
+	for _, item := range items {
+		if _, ok := item[continuesAttribute]; ok {
+			continue
+		}
+
+		entry, err := s.parseIndexEntry(item, fromPosition)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+
+		for _, version := range entry.versions {
+			partition := selectPartition(version, s.eventsPerItem)
+			if ref := makePartitionRef(entry.aggregateID, partition); !seen[ref] {
+				seen[ref] = true
+				refs[entry.aggregateID] = append(refs[entry.aggregateID], partition)
+			}
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	partitions, err := s.batchGetPartitions(ctx, refs)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var (
+			history   eventsource.History
+			positions = map[int]int64{}
+		)
+		for i, version := range entry.versions {
+			item := partitions[makePartitionRef(entry.aggregateID, selectPartition(version, s.eventsPerItem))]
+			if av, ok := item[makeKey(version)]; ok {
+				history = append(history, eventsource.Record{Version: version, Data: av.B})
+				positions[version] = entry.positions[i]
+			}
+		}
+
+		upcasted, err := s.upcasters.Upcast(history...)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range upcasted {
+			if err := fn(GlobalRecord{Position: positions[record.Version], AggregateID: entry.aggregateID, Record: record}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
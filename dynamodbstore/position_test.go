@@ -0,0 +1,303 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// positionTable applies transactions to a memoryTable, evaluating only the condition on the global
+// position.  contend advances the position ahead of the next transaction as a concurrent save would
+// and conflicts cancels that many transactions as though another were writing the position.
+type positionTable struct {
+	*memoryTable
+	contend   int64
+	conflicts int
+}
+
+func (p *positionTable) setPosition(position int64) {
+	p.put(map[string]*dynamodb.AttributeValue{
+		HashKey:           {S: aws.String(positionKey)},
+		RangeKey:          {N: aws.String("0")},
+		positionAttribute: {N: aws.String(strconv.FormatInt(position, 10))},
+	})
+}
+
+func (p *positionTable) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, error) {
+	current, _ := (&Store{api: p.memoryTable, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey}).loadPosition(ctx)
+	if p.contend > 0 {
+		current += p.contend
+		p.setPosition(current)
+		p.contend = 0
+	}
+
+	reasons := make([]string, len(input.TransactItems))
+	failed := false
+	for i, item := range input.TransactItems {
+		reasons[i] = "None"
+		if item.Update == nil || *item.Update.Key[HashKey].S != positionKey {
+			continue
+		}
+		if p.conflicts > 0 {
+			p.conflicts--
+			reasons[i] = awsReasonTransactionConflict
+			failed = true
+			continue
+		}
+
+		want := int64(0)
+		if av, ok := item.Update.ExpressionAttributeValues[":current"]; ok {
+			want, _ = strconv.ParseInt(*av.N, 10, 64)
+		}
+		if want != current {
+			reasons[i] = awsReasonConditionalCheckFailed
+			failed = true
+		}
+	}
+	if failed {
+		message := "Transaction cancelled, please refer cancellation reasons for specific reasons [" + strings.Join(reasons, ", ") + "]"
+		return nil, awserr.New(dynamodb.ErrCodeTransactionCanceledException, message, nil)
+	}
+
+	for _, item := range input.TransactItems {
+		switch {
+		case item.Update != nil:
+			p.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+				Key:                       item.Update.Key,
+				UpdateExpression:          item.Update.UpdateExpression,
+				ExpressionAttributeNames:  item.Update.ExpressionAttributeNames,
+				ExpressionAttributeValues: item.Update.ExpressionAttributeValues,
+			})
+		case item.Put != nil:
+			p.put(item.Put.Item)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func readAll(t *testing.T, store *Store, fromPosition int64) []string {
+	var found []string
+	err := store.ReadAll(context.Background(), fromPosition, func(r GlobalRecord) error {
+		found = append(found, strconv.FormatInt(r.Position, 10)+":"+r.AggregateID+"/"+strconv.Itoa(r.Record.Version))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	return found
+}
+
+func TestStore_ReadAll(t *testing.T) {
+	ctx := context.Background()
+	api := &positionTable{memoryTable: &memoryTable{}}
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(2), WithGlobalPosition(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1}, eventsource.Record{Version: 2}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "b", eventsource.Record{Version: 1}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	err = store.SaveMulti(ctx, map[string][]eventsource.Record{
+		"c": {{Version: 1}, {Version: 2}},
+		"a": {{Version: 3}},
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	testCases := map[string]struct {
+		From     int64
+		Expected []string
+	}{
+		"all": {
+			Expected: []string{"1:a/1", "2:a/2", "3:b/1", "4:a/3", "5:c/1", "6:c/2"},
+		},
+		"within save": {
+			From:     6,
+			Expected: []string{"6:c/2"},
+		},
+		"caught up": {
+			From: 7,
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if got := readAll(t, store, tc.From); !reflect.DeepEqual(got, tc.Expected) {
+				t.Fatalf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestStore_ReadAllAcrossBuckets(t *testing.T) {
+	ctx := context.Background()
+	api := &positionTable{memoryTable: &memoryTable{}}
+	api.setPosition(positionBucketSize - 2)
+
+	store, err := New("blah", WithDynamoDB(api), WithGlobalPosition(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// positions 999 through 1001 are indexed in the first bucket
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1}, eventsource.Record{Version: 2}, eventsource.Record{Version: 3}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := store.Save(ctx, "b", eventsource.Record{Version: 1}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []string{"1000:a/2", "1001:a/3", "1002:b/1"}
+	if got := readAll(t, store, positionBucketSize); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_ReadAllLargeSave(t *testing.T) {
+	ctx := context.Background()
+	api := &positionTable{memoryTable: &memoryTable{}}
+	api.setPosition(positionBucketSize - 2)
+
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(100), WithGlobalPosition(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// positions 999 through 2100 span three buckets
+	var records []eventsource.Record
+	for version := 1; version <= positionBucketSize+102; version++ {
+		records = append(records, eventsource.Record{Version: version})
+	}
+	if err := store.Save(ctx, "a", records...); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	floor, err := store.positionFloor(ctx, 2*positionBucketSize+50)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := floor, int64(positionBucketSize-1); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	want := []string{"2099:a/1101", "2100:a/1102"}
+	if got := readAll(t, store, 2*positionBucketSize+99); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_ReadAllBatched(t *testing.T) {
+	ctx := context.Background()
+	api := &positionTable{memoryTable: &memoryTable{}}
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(1), WithGlobalPosition(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	for _, aggregateID := range []string{"a", "b", "c"} {
+		if err := store.Save(ctx, aggregateID, eventsource.Record{Version: 1}, eventsource.Record{Version: 2}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	want := []string{"1:a/1", "2:a/2", "3:b/1", "4:b/2", "5:c/1", "6:c/2"}
+	if got := readAll(t, store, 0); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := api.batchGets, 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// keys left unprocessed are read again
+	api.batchGets, api.batchLimit = 0, 4
+	if got := readAll(t, store, 0); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := api.batchGets, 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SavePositionContention(t *testing.T) {
+	ctx := context.Background()
+	api := &positionTable{memoryTable: &memoryTable{}, contend: 5}
+	store, err := New("blah", WithDynamoDB(api), WithGlobalPosition(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	position, err := store.loadPosition(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := position, int64(6); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := readAll(t, store, 6), []string{"6:a/1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SavePositionTransactionConflict(t *testing.T) {
+	ctx := context.Background()
+	api := &positionTable{memoryTable: &memoryTable{}, conflicts: 2}
+	store, err := New("blah", WithDynamoDB(api), WithGlobalPosition(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := store.Save(ctx, "a", eventsource.Record{Version: 1}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := readAll(t, store, 1), []string{"1:a/1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestPositionContended(t *testing.T) {
+	testCases := map[string]struct {
+		Reasons  string
+		Expected bool
+	}{
+		"position":           {Reasons: "[None, ConditionalCheckFailed]", Expected: true},
+		"position conflict":  {Reasons: "[None, TransactionConflict]", Expected: true},
+		"event conflict":     {Reasons: "[TransactionConflict, None]"},
+		"event and position": {Reasons: "[ConditionalCheckFailed, ConditionalCheckFailed]"},
+		"event":              {Reasons: "[ConditionalCheckFailed, None]"},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			err := awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons "+tc.Reasons, nil)
+			if got := positionContended(err, 1); got != tc.Expected {
+				t.Fatalf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestPositionBackoff(t *testing.T) {
+	for attempt := 1; attempt <= maxPositionAttempts; attempt++ {
+		limit := ExponentialBackoff(positionBackoffBase, positionBackoffMax)(attempt)
+		if got := positionBackoff(attempt); got < 0 || got > limit {
+			t.Fatalf("got %v; want between 0 and %v", got, limit)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package projection builds read models from the events of an eventsource table
+package projection
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore/subscribe"
+)
+
+// Projector applies the records of an aggregate, in version order, to a read model.  Records may be
+// redelivered so projectors should be idempotent; see ReadModel.
+type Projector interface {
+	Project(ctx context.Context, aggregateID string, records []eventsource.Record) error
+}
+
+// ProjectorFunc provides a func alternative for declaring a Projector
+type ProjectorFunc func(ctx context.Context, aggregateID string, records []eventsource.Record) error
+
+// Project implements Projector
+func (fn ProjectorFunc) Project(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+	return fn(ctx, aggregateID, records)
+}
+
+// Rebuild replays the complete history of every aggregate through the projector.  Typically used
+// after ReadModel.Reset or against a new read model table.
+func Rebuild(ctx context.Context, history subscribe.HistoryReader, projector Projector) error {
+	return history.ListAggregates(ctx, func(aggregateID string) error {
+		records, err := history.Load(ctx, aggregateID, 0, 0)
+		if err != nil {
+			return fmt.Errorf("unable to load history for aggregate, %v - %v", aggregateID, err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+
+		return projector.Project(ctx, aggregateID, records)
+	})
+}
+
+// Run rebuilds the projection from history and then keeps it current from the dynamodb stream of
+// the table until the context is cancelled.  Options are passed to the underlying subscriber.
+func Run(ctx context.Context, history subscribe.HistoryReader, streamARN string, projector Projector, opts ...subscribe.Option) error {
+	return subscribe.NewCatchUp(history, streamARN, projector.Project, opts...).Run(ctx)
+}
+
+// HandlerOption represents a functional configuration of DynamoDBEventHandler
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	alert dynamodbstore.IntegrityAlerter
+}
+
+// WithIntegrityAlerter specifies where DynamoDBEventHandler reports stream records that modify or
+// remove committed events; by default they are logged
+func WithIntegrityAlerter(alert dynamodbstore.IntegrityAlerter) HandlerOption {
+	return func(o *handlerOptions) {
+		o.alert = alert
+	}
+}
+
+// DynamoDBEventHandler returns a lambda handler that decodes the stream records of an eventsource
+// table using dynamodbstore.ParseEventRecord and passes the new records to the projector.  Integrity
+// violations are permanent so are passed to the alerter, see WithIntegrityAlerter, rather than
+// failing the batch.
+func DynamoDBEventHandler(projector Projector, opts ...HandlerOption) func(ctx context.Context, event events.DynamoDBEvent) error {
+	options := handlerOptions{
+		alert: dynamodbstore.LogIntegrityViolation,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(ctx context.Context, event events.DynamoDBEvent) error {
+		for _, record := range event.Records {
+			changeSet, err := dynamodbstore.ParseEventRecord(record)
+			if v, ok := err.(*dynamodbstore.IntegrityError); ok {
+				err = options.alert(ctx, v)
+			}
+			if err != nil {
+				return err
+			}
+			if len(changeSet.Records) == 0 {
+				continue
+			}
+
+			if err := projector.Project(ctx, changeSet.AggregateID, changeSet.Records); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
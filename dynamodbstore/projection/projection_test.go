@@ -0,0 +1,162 @@
+package projection
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+type memoryHistory struct {
+	events map[string]eventsource.History
+	listed []string
+}
+
+func (m *memoryHistory) ListAggregates(ctx context.Context, fn func(aggregateID string) error) error {
+	for _, aggregateID := range m.listed {
+		if err := fn(aggregateID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryHistory) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	return m.events[aggregateID], nil
+}
+
+type delivery struct {
+	AggregateID string
+	Records     []eventsource.Record
+}
+
+func recorder(deliveries *[]delivery) ProjectorFunc {
+	return func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		*deliveries = append(*deliveries, delivery{AggregateID: aggregateID, Records: records})
+		return nil
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	history := &memoryHistory{
+		listed: []string{"abc", "empty", "def"},
+		events: map[string]eventsource.History{
+			"abc": {{Version: 1, Data: []byte("a")}, {Version: 2, Data: []byte("b")}},
+			"def": {{Version: 1, Data: []byte("c")}},
+		},
+	}
+
+	var got []delivery
+	if err := Rebuild(context.Background(), history, recorder(&got)); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []delivery{
+		{AggregateID: "abc", Records: history.events["abc"]},
+		{AggregateID: "def", Records: history.events["def"]},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v; want %#v", got, want)
+	}
+}
+
+func TestRebuild_ProjectorError(t *testing.T) {
+	history := &memoryHistory{
+		listed: []string{"abc", "def"},
+		events: map[string]eventsource.History{
+			"abc": {{Version: 1}},
+			"def": {{Version: 1}},
+		},
+	}
+
+	boom := errors.New("boom")
+	calls := 0
+	err := Rebuild(context.Background(), history, ProjectorFunc(func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		calls++
+		return boom
+	}))
+	if err != boom {
+		t.Fatalf("got %v; want %v", err, boom)
+	}
+	if calls != 1 {
+		t.Fatalf("got %v calls; want 1", calls)
+	}
+}
+
+func TestDynamoDBEventHandler(t *testing.T) {
+	event := events.DynamoDBEvent{
+		Records: []events.DynamoDBEventRecord{
+			{
+				Change: events.DynamoDBStreamRecord{
+					Keys: map[string]events.DynamoDBAttributeValue{
+						dynamodbstore.HashKey:  events.NewStringAttribute("abc"),
+						dynamodbstore.RangeKey: events.NewNumberAttribute("0"),
+					},
+					OldImage: map[string]events.DynamoDBAttributeValue{
+						"_1": events.NewBinaryAttribute([]byte("a")),
+					},
+					NewImage: map[string]events.DynamoDBAttributeValue{
+						"_1": events.NewBinaryAttribute([]byte("a")),
+						"_2": events.NewBinaryAttribute([]byte("b")),
+					},
+				},
+			},
+			{
+				// alters a committed event while appending another
+				EventName: "MODIFY",
+				Change: events.DynamoDBStreamRecord{
+					Keys: map[string]events.DynamoDBAttributeValue{
+						dynamodbstore.HashKey:  events.NewStringAttribute("ghi"),
+						dynamodbstore.RangeKey: events.NewNumberAttribute("0"),
+					},
+					OldImage: map[string]events.DynamoDBAttributeValue{
+						"_1": events.NewBinaryAttribute([]byte("a")),
+					},
+					NewImage: map[string]events.DynamoDBAttributeValue{
+						"_1": events.NewBinaryAttribute([]byte("altered")),
+						"_2": events.NewBinaryAttribute([]byte("b")),
+					},
+				},
+			},
+			{
+				// head updates carry no new records
+				Change: events.DynamoDBStreamRecord{
+					Keys: map[string]events.DynamoDBAttributeValue{
+						dynamodbstore.HashKey:  events.NewStringAttribute("def"),
+						dynamodbstore.RangeKey: events.NewNumberAttribute("-1"),
+					},
+					NewImage: map[string]events.DynamoDBAttributeValue{
+						"head": events.NewNumberAttribute("1"),
+					},
+				},
+			},
+		},
+	}
+
+	var (
+		got        []delivery
+		violations []string
+	)
+	handler := DynamoDBEventHandler(recorder(&got), WithIntegrityAlerter(func(ctx context.Context, violation *dynamodbstore.IntegrityError) error {
+		violations = append(violations, violation.AggregateID)
+		return nil
+	}))
+	if err := handler(context.Background(), event); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []delivery{
+		{AggregateID: "abc", Records: []eventsource.Record{{Version: 2, Data: []byte("b")}}},
+		{AggregateID: "ghi", Records: []eventsource.Record{{Version: 2, Data: []byte("b")}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v; want %#v", got, want)
+	}
+	if got, want := violations, []string{"ghi"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
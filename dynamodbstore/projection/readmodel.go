@@ -0,0 +1,217 @@
+package projection
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+const (
+	// IDKey is the default hash key of read model tables
+	IDKey = "id"
+
+	// VersionAttribute holds the version of the last record applied to the read model item
+	VersionAttribute = "version"
+)
+
+// ReadModel stores one item per aggregate in a dynamodb table.  Every write is guarded by the version
+// of the last record applied to the item so redelivered records become no-ops.
+type ReadModel struct {
+	api       dynamodbiface.DynamoDBAPI
+	tableName string
+	hashKey   string
+}
+
+// ReadModelOption represents a functional configuration of *ReadModel
+type ReadModelOption func(*ReadModel)
+
+// WithHashKey specifies the hash key of the read model table; defaults to IDKey
+func WithHashKey(hashKey string) ReadModelOption {
+	return func(r *ReadModel) {
+		r.hashKey = hashKey
+	}
+}
+
+// NewReadModel constructs a ReadModel backed by the specified table
+func NewReadModel(api dynamodbiface.DynamoDBAPI, tableName string, opts ...ReadModelOption) *ReadModel {
+	r := &ReadModel{
+		api:       api,
+		tableName: tableName,
+		hashKey:   IDKey,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func (r *ReadModel) key(aggregateID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		r.hashKey: {S: aws.String(aggregateID)},
+	}
+}
+
+// makePutInput replaces the item for the aggregate provided version is newer than the one applied
+func (r *ReadModel) makePutInput(aggregateID string, version int, item map[string]*dynamodb.AttributeValue) *dynamodb.PutItemInput {
+	v := map[string]*dynamodb.AttributeValue{}
+	for key, value := range item {
+		v[key] = value
+	}
+	for key, value := range r.key(aggregateID) {
+		v[key] = value
+	}
+	v[VersionAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(version))}
+
+	return &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                v,
+		ConditionExpression: aws.String("attribute_not_exists(#version) OR #version < :version"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String(VersionAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(version))},
+		},
+	}
+}
+
+// Put replaces the read model item of the aggregate with item, recording version as applied.  Returns
+// false, without error, if a record at or beyond version has already been applied.
+func (r *ReadModel) Put(ctx context.Context, aggregateID string, version int, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	if _, err := r.api.PutItemWithContext(ctx, r.makePutInput(aggregateID, version, item)); err != nil {
+		if dynamodbstore.IsConditionalCheckFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to put read model for aggregate, %v - %v", aggregateID, err)
+	}
+	return true, nil
+}
+
+// makeUpdateInput applies the SET update expression provided version is newer than the one applied
+func (r *ReadModel) makeUpdateInput(aggregateID string, version int, setExpr string, names map[string]*string, values map[string]*dynamodb.AttributeValue) *dynamodb.UpdateItemInput {
+	input := &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 r.key(aggregateID),
+		ConditionExpression: aws.String("attribute_not_exists(#version) OR #version < :version"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String(VersionAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(version))},
+		},
+	}
+	for k, v := range names {
+		input.ExpressionAttributeNames[k] = v
+	}
+	for k, v := range values {
+		input.ExpressionAttributeValues[k] = v
+	}
+
+	if setExpr == "" {
+		input.UpdateExpression = aws.String("SET #version = :version")
+	} else {
+		input.UpdateExpression = aws.String("SET #version = :version, " + setExpr)
+	}
+
+	return input
+}
+
+// Update applies the assignments of a SET update expression, e.g. "#balance = #balance + :amount", to
+// the read model item of the aggregate and records version as applied.  Returns false, without error,
+// if a record at or beyond version has already been applied.  The names #version and :version are
+// reserved.
+func (r *ReadModel) Update(ctx context.Context, aggregateID string, version int, setExpr string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (bool, error) {
+	input := r.makeUpdateInput(aggregateID, version, setExpr, names, values)
+	if _, err := r.api.UpdateItemWithContext(ctx, input); err != nil {
+		if dynamodbstore.IsConditionalCheckFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to update read model for aggregate, %v - %v", aggregateID, err)
+	}
+	return true, nil
+}
+
+// Get returns the read model item of the aggregate along with the version last applied; nil if the
+// aggregate has no item
+func (r *ReadModel) Get(ctx context.Context, aggregateID string) (map[string]*dynamodb.AttributeValue, int, error) {
+	out, err := r.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(r.tableName),
+		Key:            r.key(aggregateID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to get read model for aggregate, %v - %v", aggregateID, err)
+	}
+	if out.Item == nil {
+		return nil, 0, nil
+	}
+
+	version := 0
+	if av, ok := out.Item[VersionAttribute]; ok && av.N != nil {
+		version, _ = strconv.Atoi(*av.N)
+	}
+	return out.Item, version, nil
+}
+
+// Reset deletes every item in the read model in preparation for a Rebuild
+func (r *ReadModel) Reset(ctx context.Context) error {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(r.tableName),
+		ProjectionExpression: aws.String("#key"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key": aws.String(r.hashKey),
+		},
+	}
+
+	for {
+		out, err := r.api.ScanWithContext(ctx, input)
+		if err != nil {
+			return fmt.Errorf("unable to scan read model, %v - %v", r.tableName, err)
+		}
+
+		for _, item := range out.Items {
+			_, err := r.api.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+				TableName: aws.String(r.tableName),
+				Key:       item,
+			})
+			if err != nil {
+				return fmt.Errorf("unable to delete read model item - %v", err)
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// MakeCreateReadModelTableInput returns the definition of a read model table suitable for use with
+// NewReadModel.  The same options should be passed to both.
+func MakeCreateReadModelTableInput(tableName string, opts ...ReadModelOption) *dynamodb.CreateTableInput {
+	r := NewReadModel(nil, tableName, opts...)
+
+	return &dynamodb.CreateTableInput{
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		TableName:   aws.String(tableName),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(r.hashKey),
+				AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String(r.hashKey),
+				KeyType:       aws.String(dynamodb.KeyTypeHash),
+			},
+		},
+	}
+}
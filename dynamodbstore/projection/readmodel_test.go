@@ -0,0 +1,193 @@
+package projection
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// readModelAPI holds items in memory and enforces the version guard of the read model
+type readModelAPI struct {
+	dynamodbiface.DynamoDBAPI
+	items   map[string]map[string]*dynamodb.AttributeValue
+	deleted []string
+}
+
+func (r *readModelAPI) guard(id string, values map[string]*dynamodb.AttributeValue) error {
+	item, ok := r.items[id]
+	if !ok {
+		return nil
+	}
+	current, _ := strconv.Atoi(*item[VersionAttribute].N)
+	version, _ := strconv.Atoi(*values[":version"].N)
+	if current >= version {
+		return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	return nil
+}
+
+func (r *readModelAPI) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	id := *input.Item[IDKey].S
+	if err := r.guard(id, input.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	r.items[id] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (r *readModelAPI) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	id := *input.Key[IDKey].S
+	if err := r.guard(id, input.ExpressionAttributeValues); err != nil {
+		return nil, err
+	}
+	item, ok := r.items[id]
+	if !ok {
+		item = map[string]*dynamodb.AttributeValue{IDKey: input.Key[IDKey]}
+		r.items[id] = item
+	}
+	item[VersionAttribute] = input.ExpressionAttributeValues[":version"]
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (r *readModelAPI) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: r.items[*input.Key[IDKey].S]}, nil
+}
+
+func (r *readModelAPI) ScanWithContext(_ aws.Context, _ *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	for id := range r.items {
+		items = append(items, map[string]*dynamodb.AttributeValue{IDKey: {S: aws.String(id)}})
+	}
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
+
+func (r *readModelAPI) DeleteItemWithContext(_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	id := *input.Key[IDKey].S
+	r.deleted = append(r.deleted, id)
+	delete(r.items, id)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestReadModel_Put(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		api   = &readModelAPI{items: map[string]map[string]*dynamodb.AttributeValue{}}
+		model = NewReadModel(api, "table")
+		item  = map[string]*dynamodb.AttributeValue{"name": {S: aws.String("first")}}
+	)
+
+	applied, err := model.Put(ctx, "abc", 2, item)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if !applied {
+		t.Fatalf("got false; want true")
+	}
+
+	// redelivery of an older or the same version is a no-op
+	for _, version := range []int{1, 2} {
+		applied, err := model.Put(ctx, "abc", version, map[string]*dynamodb.AttributeValue{"name": {S: aws.String("stale")}})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if applied {
+			t.Fatalf("got true; want false for version %v", version)
+		}
+	}
+
+	got, version, err := model.Get(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if version != 2 {
+		t.Fatalf("got %v; want 2", version)
+	}
+	if got, want := *got["name"].S, "first"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestReadModel_Update(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		api   = &readModelAPI{items: map[string]map[string]*dynamodb.AttributeValue{}}
+		model = NewReadModel(api, "table")
+	)
+
+	applied, err := model.Update(ctx, "abc", 1, "", nil, nil)
+	if err != nil || !applied {
+		t.Fatalf("got %v, %v; want true, nil", applied, err)
+	}
+	applied, err = model.Update(ctx, "abc", 1, "", nil, nil)
+	if err != nil || applied {
+		t.Fatalf("got %v, %v; want false, nil", applied, err)
+	}
+
+	_, version, err := model.Get(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if version != 1 {
+		t.Fatalf("got %v; want 1", version)
+	}
+}
+
+func TestReadModel_makeUpdateInput(t *testing.T) {
+	model := NewReadModel(nil, "table", WithHashKey("pk"))
+	input := model.makeUpdateInput("abc", 3, "#count = #count + :one",
+		map[string]*string{"#count": aws.String("count")},
+		map[string]*dynamodb.AttributeValue{":one": {N: aws.String("1")}},
+	)
+
+	if got, want := *input.UpdateExpression, "SET #version = :version, #count = #count + :one"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := input.Key, map[string]*dynamodb.AttributeValue{"pk": {S: aws.String("abc")}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := len(input.ExpressionAttributeNames), 2; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *input.ExpressionAttributeValues[":version"].N, "3"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestReadModel_Reset(t *testing.T) {
+	api := &readModelAPI{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	model := NewReadModel(api, "table")
+
+	for _, id := range []string{"abc", "def"} {
+		if _, err := model.Put(context.Background(), id, 1, nil); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	if err := model.Reset(context.Background()); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got := len(api.items); got != 0 {
+		t.Fatalf("got %v items; want 0", got)
+	}
+	if got := len(api.deleted); got != 2 {
+		t.Fatalf("got %v deletes; want 2", got)
+	}
+}
+
+func TestMakeCreateReadModelTableInput(t *testing.T) {
+	input := MakeCreateReadModelTableInput("table", WithHashKey("pk"))
+
+	if got, want := *input.KeySchema[0].AttributeName, "pk"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *input.AttributeDefinitions[0].AttributeName, "pk"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,221 @@
+package dynamodbstore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/golang/protobuf/proto"
+)
+
+var (
+	errInvalidRecordType = errors.New("record has no event type")
+	errNotProtoMessage   = errors.New("event is not a proto.Message")
+)
+
+// Codec marshals and unmarshals the events of a single type
+type Codec interface {
+	// Marshal encodes the event
+	Marshal(event eventsource.Event) ([]byte, error)
+
+	// Unmarshal decodes the event
+	Unmarshal(data []byte) (eventsource.Event, error)
+}
+
+type jsonCodec struct {
+	t reflect.Type
+}
+
+// NewJSONCodec returns a Codec that encodes events of the same type as event using encoding/json
+func NewJSONCodec(event eventsource.Event) Codec {
+	_, t := eventsource.EventType(event)
+	return jsonCodec{t: t}
+}
+
+func (c jsonCodec) Marshal(event eventsource.Event) ([]byte, error) {
+	return json.Marshal(event)
+}
+
+func (c jsonCodec) Unmarshal(data []byte) (eventsource.Event, error) {
+	v := reflect.New(c.t).Interface()
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return v.(eventsource.Event), nil
+}
+
+type gobCodec struct {
+	t reflect.Type
+}
+
+// NewGobCodec returns a Codec that encodes events of the same type as event using encoding/gob
+func NewGobCodec(event eventsource.Event) Codec {
+	_, t := eventsource.EventType(event)
+	return gobCodec{t: t}
+}
+
+func (c gobCodec) Marshal(event eventsource.Event) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	if err := gob.NewEncoder(buf).Encode(event); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gobCodec) Unmarshal(data []byte) (eventsource.Event, error) {
+	v := reflect.New(c.t).Interface()
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return nil, err
+	}
+	return v.(eventsource.Event), nil
+}
+
+type protoCodec struct {
+	t reflect.Type
+}
+
+// NewProtoCodec returns a Codec that encodes events of the same type as event using protocol buffers;
+// event must be a proto.Message, typically a type generated by protoc-gen-go extended with the methods
+// of eventsource.Event
+func NewProtoCodec(event eventsource.Event) Codec {
+	_, t := eventsource.EventType(event)
+	return protoCodec{t: t}
+}
+
+func (c protoCodec) Marshal(event eventsource.Event) ([]byte, error) {
+	m, ok := event.(proto.Message)
+	if !ok {
+		return nil, errNotProtoMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (c protoCodec) Unmarshal(data []byte) (eventsource.Event, error) {
+	v := reflect.New(c.t).Interface()
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errNotProtoMessage
+	}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return v.(eventsource.Event), nil
+}
+
+// recordEnvelope is the form in which Registry stores each event.  Events encoded as json are held,
+// as is, in Data so records match those written by eventsource.JSONSerializer and may be read by
+// JSONSchemaReader; other encodings are held in Binary.
+type recordEnvelope struct {
+	Type   string          `json:"t"`
+	Data   json.RawMessage `json:"d,omitempty"`
+	Binary []byte          `json:"b,omitempty"`
+}
+
+// Registry holds the Codec for each event type, keyed by event type name.  Registry satisfies
+// eventsource.Serializer.
+//
+// Each record is stored as a json envelope, {"t":"{type}","d":{event}}, the same envelope used by
+// eventsource.JSONSerializer, so records may be decoded without knowing their type in advance.  Events
+// whose encoding is not json, e.g. gob or protocol buffers, are stored base64 encoded in place of the
+// event, {"t":"{type}","b":"{base64}"}.
+type Registry struct {
+	codecs map[string]Codec
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		codecs: map[string]Codec{},
+	}
+}
+
+// Register associates the codec with the type of event; may be called more than once
+func (r *Registry) Register(event eventsource.Event, codec Codec) *Registry {
+	eventType, _ := eventsource.EventType(event)
+	r.codecs[eventType] = codec
+	return r
+}
+
+// isCompactJSON returns true if data is json that is held unchanged within an envelope
+func isCompactJSON(data []byte) bool {
+	if !json.Valid(data) {
+		return false
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := json.Compact(buf, data); err != nil {
+		return false
+	}
+	return bytes.Equal(buf.Bytes(), data)
+}
+
+// MarshalEvent implements eventsource.Serializer
+func (r *Registry) MarshalEvent(event eventsource.Event) (eventsource.Record, error) {
+	eventType, _ := eventsource.EventType(event)
+	codec, ok := r.codecs[eventType]
+	if !ok {
+		return eventsource.Record{}, eventsource.NewError(nil, eventsource.ErrUnboundEventType, "unbound event type, %v", eventType)
+	}
+
+	payload, err := codec.Marshal(event)
+	if err != nil {
+		return eventsource.Record{}, eventsource.NewError(err, eventsource.ErrInvalidEncoding, "unable to encode event, %v", eventType)
+	}
+
+	envelope := recordEnvelope{Type: eventType}
+	if isCompactJSON(payload) {
+		envelope.Data = payload
+	} else {
+		envelope.Binary = payload
+	}
+
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return eventsource.Record{}, eventsource.NewError(err, eventsource.ErrInvalidEncoding, "unable to encode event, %v", eventType)
+	}
+
+	return eventsource.Record{
+		Version: event.EventVersion(),
+		Data:    data,
+	}, nil
+}
+
+// UnmarshalEvent implements eventsource.Serializer
+func (r *Registry) UnmarshalEvent(record eventsource.Record) (eventsource.Event, error) {
+	envelope, err := readEnvelope(record.Data)
+	if err != nil {
+		return nil, eventsource.NewError(err, eventsource.ErrInvalidEncoding, "unable to read event type of event, %v", record.Version)
+	}
+
+	codec, ok := r.codecs[envelope.Type]
+	if !ok {
+		return nil, eventsource.NewError(nil, eventsource.ErrUnboundEventType, "unbound event type, %v", envelope.Type)
+	}
+
+	payload := []byte(envelope.Data)
+	if payload == nil {
+		payload = envelope.Binary
+	}
+
+	event, err := codec.Unmarshal(payload)
+	if err != nil {
+		return nil, eventsource.NewError(err, eventsource.ErrInvalidEncoding, "unable to decode event, %v, of type %v", record.Version, envelope.Type)
+	}
+
+	return event, nil
+}
+
+// readEnvelope parses the envelope holding the event type and the encoded event
+func readEnvelope(data []byte) (recordEnvelope, error) {
+	var envelope recordEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return recordEnvelope{}, err
+	}
+	if envelope.Type == "" {
+		return recordEnvelope{}, errInvalidRecordType
+	}
+	return envelope, nil
+}
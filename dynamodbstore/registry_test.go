@@ -0,0 +1,124 @@
+package dynamodbstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/golang/protobuf/proto"
+)
+
+type OrderCreated struct {
+	eventsource.Model
+	Customer string
+}
+
+type OrderShipped struct {
+	eventsource.Model
+	Carrier string
+}
+
+// OrderCancelled is a protocol buffer message written by hand in the form generated by protoc-gen-go
+type OrderCancelled struct {
+	ID      string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Version int32  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Reason  string `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+}
+
+func (m *OrderCancelled) Reset()         { *m = OrderCancelled{} }
+func (m *OrderCancelled) String() string { return proto.CompactTextString(m) }
+func (*OrderCancelled) ProtoMessage()    {}
+
+func (m *OrderCancelled) AggregateID() string { return m.ID }
+func (m *OrderCancelled) EventVersion() int   { return int(m.Version) }
+func (m *OrderCancelled) EventAt() time.Time  { return time.Time{} }
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry().
+		Register(OrderCreated{}, NewJSONCodec(OrderCreated{})).
+		Register(&OrderShipped{}, NewGobCodec(&OrderShipped{})).
+		Register(&OrderCancelled{}, NewProtoCodec(&OrderCancelled{}))
+
+	events := []eventsource.Event{
+		&OrderCreated{Model: eventsource.Model{ID: "abc", Version: 1}, Customer: "alice"},
+		&OrderShipped{Model: eventsource.Model{ID: "abc", Version: 2}, Carrier: "ups"},
+		&OrderCancelled{ID: "abc", Version: 3, Reason: "late"},
+	}
+
+	for _, event := range events {
+		record, err := registry.MarshalEvent(event)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := record.Version, event.EventVersion(); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		got, err := registry.UnmarshalEvent(record)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if !reflect.DeepEqual(got, event) {
+			t.Fatalf("got %#v; want %#v", got, event)
+		}
+	}
+}
+
+func TestRegistry_Errors(t *testing.T) {
+	registry := NewRegistry().Register(OrderCreated{}, NewJSONCodec(OrderCreated{}))
+
+	if _, err := registry.MarshalEvent(&OrderShipped{}); !eventsource.ErrHasCode(err, eventsource.ErrUnboundEventType) {
+		t.Fatalf("got %v; want %v", err, eventsource.ErrUnboundEventType)
+	}
+
+	shipped, err := NewRegistry().Register(OrderShipped{}, NewJSONCodec(OrderShipped{})).MarshalEvent(&OrderShipped{})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, err := registry.UnmarshalEvent(shipped); !eventsource.ErrHasCode(err, eventsource.ErrUnboundEventType) {
+		t.Fatalf("got %v; want %v", err, eventsource.ErrUnboundEventType)
+	}
+
+	for _, data := range []string{"", "{}", `{"t":"OrderCreated","d":"abc"}`, `{"t":"OrderCreated","b":"eyI="}`} {
+		if _, err := registry.UnmarshalEvent(eventsource.Record{Data: []byte(data)}); !eventsource.ErrHasCode(err, eventsource.ErrInvalidEncoding) {
+			t.Fatalf("got %v; want %v", err, eventsource.ErrInvalidEncoding)
+		}
+	}
+}
+
+func TestRegistry_JSONEnvelope(t *testing.T) {
+	type OrderCreatedV2 struct {
+		OrderCreated
+		Schema int `json:"schema"`
+	}
+
+	registry := NewRegistry().Register(&OrderCreatedV2{}, NewJSONCodec(&OrderCreatedV2{}))
+	event := &OrderCreatedV2{
+		OrderCreated: OrderCreated{Model: eventsource.Model{ID: "abc", Version: 1}, Customer: "alice"},
+		Schema:       2,
+	}
+
+	record, err := registry.MarshalEvent(event)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// records written through the registry may be read by eventsource.JSONSerializer
+	found, err := eventsource.NewJSONSerializer(&OrderCreatedV2{}).UnmarshalEvent(record)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if !reflect.DeepEqual(found, event) {
+		t.Fatalf("got %#v; want %#v", found, event)
+	}
+
+	// and their schema read by the upcasters
+	eventType, schemaVersion, err := JSONSchemaReader("schema")(record.Data)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if eventType != "OrderCreatedV2" || schemaVersion != 2 {
+		t.Fatalf("got %v, %v; want OrderCreatedV2, 2", eventType, schemaVersion)
+	}
+}
@@ -0,0 +1,240 @@
+package dynamodbstore
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// DefaultAttempts is the number of times Repository.Apply runs the cycle before giving up
+	DefaultAttempts = 3
+)
+
+// SnapshotSerializer encodes and decodes the state of aggregates held in snapshots
+type SnapshotSerializer interface {
+	// MarshalSnapshot encodes the state of the aggregate
+	MarshalSnapshot(aggregate eventsource.Aggregate) ([]byte, error)
+
+	// UnmarshalSnapshot decodes the state held in data into the empty aggregate
+	UnmarshalSnapshot(data []byte, aggregate eventsource.Aggregate) error
+}
+
+// jsonSnapshotSerializer encodes aggregates using encoding/json
+type jsonSnapshotSerializer struct{}
+
+func (jsonSnapshotSerializer) MarshalSnapshot(aggregate eventsource.Aggregate) ([]byte, error) {
+	return json.Marshal(aggregate)
+}
+
+func (jsonSnapshotSerializer) UnmarshalSnapshot(data []byte, aggregate eventsource.Aggregate) error {
+	return json.Unmarshal(data, aggregate)
+}
+
+// CommandFunc returns the events to save given the current state and version of the aggregate.  The
+// events should be numbered from version+1.  CommandFunc may be called more than once by Apply and
+// so should not have side effects.
+type CommandFunc func(ctx context.Context, aggregate eventsource.Aggregate, version int) ([]eventsource.Event, error)
+
+// Repository loads aggregates, applies commands to them, and saves the resulting events, retrying
+// when another writer saved to the aggregate first
+type Repository struct {
+	store         eventsource.Store
+	serializer    eventsource.Serializer
+	factory       func() eventsource.Aggregate
+	attempts      int
+	backoff       func(attempt int) time.Duration
+	snapshots     SnapshotStore
+	snapshotEvery int
+	snapshotCodec SnapshotSerializer
+}
+
+// RepositoryOption represents a functional configuration of *Repository
+type RepositoryOption func(*Repository)
+
+// WithRetry specifies the number of attempts Apply makes and the delay before each retry; defaults to
+// DefaultAttempts with an exponential backoff from 50ms
+func WithRetry(attempts int, backoff func(attempt int) time.Duration) RepositoryOption {
+	return func(r *Repository) {
+		r.attempts = attempts
+		r.backoff = backoff
+	}
+}
+
+// WithSnapshots loads aggregates from their most recent snapshot and saves a new snapshot each time
+// the version of an aggregate crosses a multiple of every.  By default aggregates are encoded using
+// encoding/json which silently drops unexported fields; aggregates that hold unexported state should
+// be snapshot with their own serializer, see WithSnapshotSerializer.
+func WithSnapshots(snapshots SnapshotStore, every int) RepositoryOption {
+	return func(r *Repository) {
+		r.snapshots = snapshots
+		r.snapshotEvery = every
+	}
+}
+
+// WithSnapshotSerializer specifies how aggregates are encoded in snapshots; defaults to encoding/json
+func WithSnapshotSerializer(serializer SnapshotSerializer) RepositoryOption {
+	return func(r *Repository) {
+		r.snapshotCodec = serializer
+	}
+}
+
+// ExponentialBackoff returns a backoff that doubles from base, up to max
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			delay = max
+		}
+		return delay
+	}
+}
+
+// NewRepository returns a Repository that saves to store, typically a *Store, and uses factory to
+// construct empty aggregates.  Factory should return a pointer so snapshots may be decoded into it.
+func NewRepository(store eventsource.Store, serializer eventsource.Serializer, factory func() eventsource.Aggregate, opts ...RepositoryOption) *Repository {
+	r := &Repository{
+		store:         store,
+		serializer:    serializer,
+		factory:       factory,
+		attempts:      DefaultAttempts,
+		backoff:       ExponentialBackoff(50*time.Millisecond, time.Second),
+		snapshotCodec: jsonSnapshotSerializer{},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Load returns the current state of the aggregate along with its version; version 0 if the aggregate
+// has no events
+func (r *Repository) Load(ctx context.Context, aggregateID string) (eventsource.Aggregate, int, error) {
+	aggregate := r.factory()
+	version := 0
+
+	if r.snapshots != nil {
+		snapshot, ok, err := r.snapshots.LoadSnapshot(ctx, aggregateID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			if err := r.snapshotCodec.UnmarshalSnapshot(snapshot.Data, aggregate); err != nil {
+				return nil, 0, eventsource.NewError(err, eventsource.ErrInvalidEncoding, "unable to decode snapshot of aggregate, %v", aggregateID)
+			}
+			version = snapshot.Version
+		}
+	}
+
+	history, err := r.store.Load(ctx, aggregateID, version+1, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, record := range history {
+		event, err := r.serializer.UnmarshalEvent(record)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := aggregate.On(event); err != nil {
+			return nil, 0, err
+		}
+		version = record.Version
+	}
+
+	return aggregate, version, nil
+}
+
+// Apply loads the aggregate, runs fn against it, and saves the events fn returns.  If another writer
+// saved to the aggregate first, the cycle is repeated with the new state up to the configured number
+// of attempts.  Returns the version of the aggregate once the events are committed.
+func (r *Repository) Apply(ctx context.Context, aggregateID string, fn CommandFunc) (int, error) {
+	var err error
+	for attempt := 1; attempt <= r.attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(r.backoff(attempt - 1)):
+			}
+		}
+
+		var version int
+		version, err = r.apply(ctx, aggregateID, fn)
+		if err == nil {
+			return version, nil
+		}
+		if !isVersionConflict(err) {
+			return 0, err
+		}
+	}
+
+	return 0, err
+}
+
+func (r *Repository) apply(ctx context.Context, aggregateID string, fn CommandFunc) (int, error) {
+	aggregate, version, err := r.Load(ctx, aggregateID)
+	if err != nil {
+		return 0, err
+	}
+
+	events, err := fn(ctx, aggregate, version)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return version, nil
+	}
+
+	records := make([]eventsource.Record, 0, len(events))
+	for _, event := range events {
+		record, err := r.serializer.MarshalEvent(event)
+		if err != nil {
+			return 0, err
+		}
+		records = append(records, record)
+	}
+
+	if err := r.store.Save(ctx, aggregateID, records...); err != nil {
+		return 0, err
+	}
+
+	committed := records[len(records)-1].Version
+	r.saveSnapshot(ctx, aggregateID, aggregate, version, committed, events)
+
+	return committed, nil
+}
+
+// saveSnapshot records the state of the aggregate after the events were committed if a snapshot is
+// due.  Snapshots are an optimization so failures are ignored; the next save will try again.
+func (r *Repository) saveSnapshot(ctx context.Context, aggregateID string, aggregate eventsource.Aggregate, from, to int, events []eventsource.Event) {
+	if r.snapshots == nil || r.snapshotEvery <= 0 || from/r.snapshotEvery == to/r.snapshotEvery {
+		return
+	}
+
+	for _, event := range events {
+		if err := aggregate.On(event); err != nil {
+			return
+		}
+	}
+
+	data, err := r.snapshotCodec.MarshalSnapshot(aggregate)
+	if err != nil {
+		return
+	}
+
+	_ = r.snapshots.SaveSnapshot(ctx, aggregateID, Snapshot{Version: to, Data: data})
+}
+
+// isVersionConflict returns true if the save failed because another writer saved to the aggregate
+// first or a concurrent transaction touched the same items; either way a fresh attempt may succeed
+func isVersionConflict(err error) bool {
+	return eventsource.ErrHasCode(err, ErrVersionConflict) || isTransactionConflict(err)
+}
@@ -0,0 +1,306 @@
+package dynamodbstore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+type Account struct {
+	Balance int
+}
+
+type Deposited struct {
+	eventsource.Model
+	Amount int
+}
+
+func (a *Account) On(event eventsource.Event) error {
+	switch v := event.(type) {
+	case *Deposited:
+		a.Balance += v.Amount
+	}
+	return nil
+}
+
+// versionedStore rejects saves that do not immediately follow the current version.  beforeSave, if
+// set, is called ahead of each save to simulate a concurrent writer and errs are returned by the
+// first saves in turn.
+type versionedStore struct {
+	records    recordStore
+	saves      int
+	beforeSave func()
+	errs       []error
+}
+
+func (v *versionedStore) Save(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
+	v.saves++
+	if v.beforeSave != nil {
+		v.beforeSave()
+	}
+	if len(v.errs) > 0 {
+		err := v.errs[0]
+		v.errs = v.errs[1:]
+		return err
+	}
+	if records[0].Version != len(v.records[aggregateID])+1 {
+		return newConflictError(aggregateID)
+	}
+	return v.records.Save(ctx, aggregateID, records...)
+}
+
+func (v *versionedStore) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	var history eventsource.History
+	for _, record := range v.records[aggregateID] {
+		if record.Version >= fromVersion {
+			history = append(history, record)
+		}
+	}
+	return history, nil
+}
+
+type memorySnapshots map[string]Snapshot
+
+func (m memorySnapshots) LoadSnapshot(ctx context.Context, aggregateID string) (Snapshot, bool, error) {
+	snapshot, ok := m[aggregateID]
+	return snapshot, ok, nil
+}
+
+func (m memorySnapshots) SaveSnapshot(ctx context.Context, aggregateID string, snapshot Snapshot) error {
+	m[aggregateID] = snapshot
+	return nil
+}
+
+func deposit(amount int) CommandFunc {
+	return func(ctx context.Context, aggregate eventsource.Aggregate, version int) ([]eventsource.Event, error) {
+		return []eventsource.Event{
+			&Deposited{Model: eventsource.Model{ID: "abc", Version: version + 1}, Amount: amount},
+		}, nil
+	}
+}
+
+func newTestRepository(store eventsource.Store, opts ...RepositoryOption) *Repository {
+	serializer := eventsource.NewJSONSerializer(Deposited{})
+	factory := func() eventsource.Aggregate { return &Account{} }
+	opts = append([]RepositoryOption{WithRetry(3, func(int) time.Duration { return 0 })}, opts...)
+	return NewRepository(store, serializer, factory, opts...)
+}
+
+func TestRepository_Apply(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &versionedStore{records: recordStore{}}
+		repo  = newTestRepository(store)
+	)
+
+	for i, amount := range []int{10, 20} {
+		version, err := repo.Apply(ctx, "abc", deposit(amount))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := version, i+1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+
+	aggregate, version, err := repo.Load(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := aggregate, (&Account{Balance: 30}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if version != 2 {
+		t.Fatalf("got %v; want 2", version)
+	}
+}
+
+func TestRepository_ApplyRetry(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &versionedStore{records: recordStore{}}
+		repo  = newTestRepository(store)
+	)
+
+	// another writer saves ahead of the first attempt only
+	store.beforeSave = func() {
+		store.beforeSave = nil
+		record, _ := eventsource.NewJSONSerializer().MarshalEvent(&Deposited{Model: eventsource.Model{ID: "abc", Version: 1}, Amount: 5})
+		store.records["abc"] = append(store.records["abc"], record)
+	}
+
+	version, err := repo.Apply(ctx, "abc", deposit(10))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if version != 2 {
+		t.Fatalf("got %v; want 2", version)
+	}
+	if store.saves != 2 {
+		t.Fatalf("got %v saves; want 2", store.saves)
+	}
+
+	aggregate, _, err := repo.Load(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := aggregate.(*Account).Balance, 15; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestRepository_ApplyTransactionConflict(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &versionedStore{records: recordStore{}}
+		repo  = newTestRepository(store)
+	)
+
+	store.errs = []error{
+		awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [TransactionConflict]", nil),
+	}
+
+	version, err := repo.Apply(ctx, "abc", deposit(10))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if version != 1 {
+		t.Fatalf("got %v; want 1", version)
+	}
+	if store.saves != 2 {
+		t.Fatalf("got %v saves; want 2", store.saves)
+	}
+}
+
+func TestRepository_ApplyExhausted(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = &versionedStore{records: recordStore{}}
+		repo  = newTestRepository(store)
+	)
+
+	store.beforeSave = func() {
+		n := len(store.records["abc"])
+		record, _ := eventsource.NewJSONSerializer().MarshalEvent(&Deposited{Model: eventsource.Model{ID: "abc", Version: n + 1}, Amount: 5})
+		store.records["abc"] = append(store.records["abc"], record)
+	}
+
+	if _, err := repo.Apply(ctx, "abc", deposit(10)); !isVersionConflict(err) {
+		t.Fatalf("got %v; want version conflict", err)
+	}
+	if store.saves != 3 {
+		t.Fatalf("got %v saves; want 3", store.saves)
+	}
+}
+
+func TestRepository_ApplyCommandError(t *testing.T) {
+	var (
+		boom  = errors.New("boom")
+		store = &versionedStore{records: recordStore{}}
+		repo  = newTestRepository(store)
+	)
+
+	_, err := repo.Apply(context.Background(), "abc", func(ctx context.Context, aggregate eventsource.Aggregate, version int) ([]eventsource.Event, error) {
+		return nil, boom
+	})
+	if err != boom {
+		t.Fatalf("got %v; want %v", err, boom)
+	}
+}
+
+func TestRepository_Snapshots(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		store     = &versionedStore{records: recordStore{}}
+		snapshots = memorySnapshots{}
+		repo      = newTestRepository(store, WithSnapshots(snapshots, 2))
+	)
+
+	for _, amount := range []int{1, 2, 3} {
+		if _, err := repo.Apply(ctx, "abc", deposit(amount)); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	snapshot, ok := snapshots["abc"]
+	if !ok {
+		t.Fatalf("got false; want true")
+	}
+	if snapshot.Version != 2 {
+		t.Fatalf("got %v; want 2", snapshot.Version)
+	}
+
+	// events folded into the snapshot are not reloaded
+	store.records["abc"] = store.records["abc"][2:]
+
+	aggregate, version, err := repo.Load(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := aggregate.(*Account).Balance, 6; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if version != 3 {
+		t.Fatalf("got %v; want 3", version)
+	}
+}
+
+// balanceSerializer encodes accounts as their decimal balance
+type balanceSerializer struct{}
+
+func (balanceSerializer) MarshalSnapshot(aggregate eventsource.Aggregate) ([]byte, error) {
+	return []byte(strconv.Itoa(aggregate.(*Account).Balance)), nil
+}
+
+func (balanceSerializer) UnmarshalSnapshot(data []byte, aggregate eventsource.Aggregate) error {
+	balance, err := strconv.Atoi(string(data))
+	if err != nil {
+		return err
+	}
+	aggregate.(*Account).Balance = balance
+	return nil
+}
+
+func TestRepository_SnapshotSerializer(t *testing.T) {
+	var (
+		ctx       = context.Background()
+		store     = &versionedStore{records: recordStore{}}
+		snapshots = memorySnapshots{}
+		repo      = newTestRepository(store, WithSnapshots(snapshots, 2), WithSnapshotSerializer(balanceSerializer{}))
+	)
+
+	for _, amount := range []int{1, 2} {
+		if _, err := repo.Apply(ctx, "abc", deposit(amount)); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+	if got, want := string(snapshots["abc"].Data), "3"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	store.records["abc"] = nil
+	aggregate, _, err := repo.Load(ctx, "abc")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := aggregate.(*Account).Balance, 3; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	want := []time.Duration{10, 20, 40, 50, 50}
+	for i, w := range want {
+		if got := backoff(i + 1); got != w*time.Millisecond {
+			t.Fatalf("attempt %v: got %v; want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// reservationPrefix prefixes the hash key of reservation items to keep them apart from aggregates
+	reservationPrefix = reservedPrefix + "reservation/"
+
+	// ownerAttribute holds the id of the aggregate that holds the reservation
+	ownerAttribute = "owner"
+)
+
+// reservation represents a claim on, or release of, a unique value
+type reservation struct {
+	constraint string
+	value      string
+	release    bool
+}
+
+// Reserve claims value for the aggregate being saved.  The reservation is written in the same
+// transaction as the records and the save fails with ErrConstraintViolation if another aggregate
+// holds the value.  The reservation is also recorded in the head item of the aggregate so a hard
+// Delete can release it.  Constraint names should not contain "/".  A value may not be both
+// reserved and released by the same save.
+func Reserve(constraint, value string) SaveOption {
+	return func(o *saveOptions) {
+		o.reservations = append(o.reservations, reservation{constraint: constraint, value: value})
+	}
+}
+
+// Release gives back a value previously reserved by the aggregate being saved
+func Release(constraint, value string) SaveOption {
+	return func(o *saveOptions) {
+		o.reservations = append(o.reservations, reservation{constraint: constraint, value: value, release: true})
+	}
+}
+
+// uniqueReservations drops repeated reservations, or releases, of the same value as dynamodb rejects
+// transactions that write an item more than once.  Returns ErrInvalidReservation if a value is both
+// reserved and released.
+func uniqueReservations(reservations []reservation) ([]reservation, error) {
+	var (
+		unique []reservation
+		seen   = map[string]reservation{}
+	)
+	for _, r := range reservations {
+		name := makeReservationName(r.constraint, r.value)
+		if existing, ok := seen[name]; ok {
+			if existing.release != r.release {
+				return nil, eventsource.NewError(nil, ErrInvalidReservation, "value, %v, for constraint, %v, is both reserved and released", r.value, r.constraint)
+			}
+			continue
+		}
+		seen[name] = r
+		unique = append(unique, r)
+	}
+	return unique, nil
+}
+
+// makeReservationName returns both the hash key of the reservation item and the name of the
+// attribute that records the reservation in the head item of its owner
+func makeReservationName(constraint, value string) string {
+	return reservationPrefix + constraint + "/" + value
+}
+
+func makeReservationKey(hashKey, rangeKey, constraint, value string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		hashKey:  {S: aws.String(makeReservationName(constraint, value))},
+		rangeKey: {N: aws.String(strconv.Itoa(0))},
+	}
+}
+
+// addReservationAttributes extends the head update to record the reservations claimed, and forget
+// those released, by the save.  Any existing update expression must hold only a SET clause.
+func addReservationAttributes(update *dynamodb.Update, reservations []reservation) {
+	var set, remove []string
+	for i, r := range reservations {
+		nameRef := "#r" + strconv.Itoa(i)
+		update.ExpressionAttributeNames[nameRef] = aws.String(makeReservationName(r.constraint, r.value))
+		if r.release {
+			remove = append(remove, nameRef)
+		} else {
+			set = append(set, nameRef+" = :reserved")
+		}
+	}
+
+	expr := aws.StringValue(update.UpdateExpression)
+	if len(set) > 0 {
+		update.ExpressionAttributeValues[":reserved"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+		if expr == "" {
+			expr = "SET " + strings.Join(set, ", ")
+		} else {
+			expr += ", " + strings.Join(set, ", ")
+		}
+	}
+	if len(remove) > 0 {
+		expr = strings.TrimSpace(expr + " REMOVE " + strings.Join(remove, ", "))
+	}
+	if expr != "" {
+		update.UpdateExpression = aws.String(expr)
+	}
+}
+
+// releaseReservations gives back every reservation recorded in the head item of the aggregate
+func (s *Store) releaseReservations(ctx context.Context, aggregateID string, head map[string]*dynamodb.AttributeValue) error {
+	var names []string
+	for name := range head {
+		if strings.HasPrefix(name, reservationPrefix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	for _, name := range names {
+		input := &dynamodb.DeleteItemInput{
+			TableName: aws.String(s.tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				s.hashKey:  {S: aws.String(name)},
+				s.rangeKey: {N: aws.String(strconv.Itoa(0))},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(#owner) OR #owner = :owner"),
+			ExpressionAttributeNames: map[string]*string{
+				"#owner": aws.String(ownerAttribute),
+			},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":owner": {S: aws.String(aggregateID)},
+			},
+		}
+		s.dump(input)
+
+		if _, err := s.api.DeleteItemWithContext(ctx, input); err != nil && !IsConditionalCheckFailed(err) {
+			return err
+		}
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName:                aws.String(s.tableName),
+		Key:                      makeHeadKey(s.hashKey, s.rangeKey, aggregateID),
+		ExpressionAttributeNames: map[string]*string{},
+	}
+	var refs []string
+	for i, name := range names {
+		nameRef := "#r" + strconv.Itoa(i)
+		input.ExpressionAttributeNames[nameRef] = aws.String(name)
+		refs = append(refs, nameRef)
+	}
+	input.UpdateExpression = aws.String("REMOVE " + strings.Join(refs, ", "))
+	s.dump(input)
+
+	_, err := s.api.UpdateItemWithContext(ctx, input)
+	return err
+}
+
+// makeReservationWrite claims or releases the reservation on behalf of the aggregate.  Both are
+// idempotent for the owning aggregate.
+func makeReservationWrite(tableName, hashKey, rangeKey, aggregateID string, r reservation) *dynamodb.TransactWriteItem {
+	key := makeReservationKey(hashKey, rangeKey, r.constraint, r.value)
+	names := map[string]*string{
+		"#owner": aws.String(ownerAttribute),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":owner": {S: aws.String(aggregateID)},
+	}
+
+	if r.release {
+		return &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName:                 aws.String(tableName),
+				Key:                       key,
+				ConditionExpression:       aws.String("attribute_not_exists(#owner) OR #owner = :owner"),
+				ExpressionAttributeNames:  names,
+				ExpressionAttributeValues: values,
+			},
+		}
+	}
+
+	item := map[string]*dynamodb.AttributeValue{
+		ownerAttribute: {S: aws.String(aggregateID)},
+	}
+	for k, v := range key {
+		item[k] = v
+	}
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName:                 aws.String(tableName),
+			Item:                      item,
+			ConditionExpression:       aws.String("attribute_not_exists(#owner) OR #owner = :owner"),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		},
+	}
+}
+
+// failedReservation returns the reservation whose condition cancelled the transaction, if any.
+// Reservations are expected to start at index offset within the transaction.
+func failedReservation(err error, offset int, reservations []reservation) (reservation, bool) {
+	v, ok := err.(awserr.Error)
+	if !ok {
+		return reservation{}, false
+	}
+
+	reasons := cancellationReasons(v)
+	for i, r := range reservations {
+		if index := offset + i; index < len(reasons) && reasons[index] == awsReasonConditionalCheckFailed {
+			return r, true
+		}
+	}
+
+	return reservation{}, false
+}
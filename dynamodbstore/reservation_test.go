@@ -0,0 +1,149 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestMakeReservationWrite(t *testing.T) {
+	t.Run("reserve", func(t *testing.T) {
+		item := makeReservationWrite("table", HashKey, RangeKey, "abc", reservation{constraint: "email", value: "a@example.com"})
+		if item.Put == nil {
+			t.Fatalf("got nil; want Put")
+		}
+		if got, want := *item.Put.Item[HashKey].S, "$reservation/email/a@example.com"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *item.Put.Item[ownerAttribute].S, "abc"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("release", func(t *testing.T) {
+		item := makeReservationWrite("table", HashKey, RangeKey, "abc", reservation{constraint: "email", value: "a@example.com", release: true})
+		if item.Delete == nil {
+			t.Fatalf("got nil; want Delete")
+		}
+		if got, want := *item.Delete.ExpressionAttributeValues[":owner"].S, "abc"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestStore_SaveReserveConflict(t *testing.T) {
+	api := &transactAPI{
+		err: awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [None, None, None, ConditionalCheckFailed]", nil),
+	}
+	store, err := New("blah", WithDynamoDB(api))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	records := []eventsource.Record{{Version: 1, Data: []byte("a")}}
+	err = store.SaveWith(context.Background(), "abc", records,
+		Reserve("username", "alice"),
+		Reserve("email", "a@example.com"),
+	)
+	if !eventsource.ErrHasCode(err, ErrConstraintViolation) {
+		t.Fatalf("got %v; want %v", err, ErrConstraintViolation)
+	}
+	if !strings.Contains(err.Error(), "a@example.com") {
+		t.Fatalf("got %v; want email reservation", err)
+	}
+	if got, want := len(api.input.TransactItems), 4; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SaveDuplicateReservations(t *testing.T) {
+	api := &transactAPI{}
+	store, err := New("blah", WithDynamoDB(api))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	records := []eventsource.Record{{Version: 1, Data: []byte("a")}}
+
+	t.Run("repeated", func(t *testing.T) {
+		err := store.SaveWith(context.Background(), "abc", records,
+			Reserve("email", "a@example.com"),
+			Reserve("email", "a@example.com"),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		// event, head and a single reservation
+		if got, want := len(api.input.TransactItems), 3; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("reserved and released", func(t *testing.T) {
+		err := store.SaveWith(context.Background(), "abc", records,
+			Reserve("email", "a@example.com"),
+			Release("email", "a@example.com"),
+		)
+		if !eventsource.ErrHasCode(err, ErrInvalidReservation) {
+			t.Fatalf("got %v; want %v", err, ErrInvalidReservation)
+		}
+	})
+}
+
+func TestAddReservationAttributes(t *testing.T) {
+	update := makeHeadUpdate("table", HashKey, RangeKey, "abc", eventsource.Record{Version: 2})
+	addReservationAttributes(update, []reservation{
+		{constraint: "email", value: "a@example.com"},
+		{constraint: "email", value: "b@example.com", release: true},
+	})
+
+	if got, want := *update.UpdateExpression, "SET #head = :head, #r0 = :reserved REMOVE #r1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *update.ExpressionAttributeNames["#r1"], "$reservation/email/b@example.com"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_SaveReserve(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		records := []eventsource.Record{{Version: 1, Data: []byte("a")}}
+		err = store.SaveWith(ctx, "abc", records, Reserve("email", "a@example.com"))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// another aggregate may not reserve the same value
+		err = store.SaveWith(ctx, "def", records, Reserve("email", "a@example.com"))
+		if !eventsource.ErrHasCode(err, ErrConstraintViolation) {
+			t.Fatalf("got %v; want %v", err, ErrConstraintViolation)
+		}
+
+		// until the value is released
+		err = store.SaveWith(ctx, "abc", []eventsource.Record{{Version: 2, Data: []byte("b")}}, Release("email", "a@example.com"))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		err = store.SaveWith(ctx, "def", records, Reserve("email", "a@example.com"))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	})
+}
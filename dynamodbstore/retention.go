@@ -0,0 +1,231 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+const (
+	// ExpiresAttribute holds the time, in epoch seconds, after which dynamodb may delete an item whose
+	// events have all expired.  See WithTTL.
+	ExpiresAttribute = "expires"
+
+	// committedPrefix prefixes the attributes holding the commit time of each event
+	committedPrefix = "@"
+
+	// metadataPrefix prefixes the hash key of stream metadata items to keep them apart from aggregates
+	metadataPrefix = reservedPrefix + "metadata/"
+
+	// maxAgeAttribute holds the max age of the stream in nanoseconds
+	maxAgeAttribute = "maxAge"
+
+	// maxCountAttribute holds the max count of the stream
+	maxCountAttribute = "maxCount"
+
+	// retainFromAttribute holds the earliest version retained when the item was marked for removal
+	retainFromAttribute = "retainFrom"
+
+	// retainSinceAttribute holds the earliest commit time, in unix nanoseconds, retained when the
+	// item was marked for removal
+	retainSinceAttribute = "retainSince"
+)
+
+// StreamMetadata holds the retention policy of an aggregate.  Events outside the policy are hidden
+// from Load once WithRetention is enabled and their partition items are eventually removed by a
+// Scavenger.  The zero value retains every event.
+type StreamMetadata struct {
+	// MaxAge hides events committed more than MaxAge ago; zero for no limit.  Events saved without a
+	// commit time, see WithCommitTimes, are never hidden by MaxAge.
+	MaxAge time.Duration
+
+	// MaxCount hides all but the MaxCount most recent events; zero for no limit.  The window is
+	// computed from the latest version so MaxCount requires the contiguous versions enforced by
+	// WithStrictVersions; with gaps in the versions fewer than MaxCount events are retained.
+	MaxCount int
+}
+
+// IsZero returns true if the metadata retains every event
+func (m StreamMetadata) IsZero() bool {
+	return m.MaxAge <= 0 && m.MaxCount <= 0
+}
+
+func makeMetadataKey(hashKey, rangeKey, aggregateID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		hashKey:  {S: aws.String(metadataPrefix + aggregateID)},
+		rangeKey: {N: aws.String(strconv.Itoa(0))},
+	}
+}
+
+// LoadStreamMetadata returns the retention policy of the aggregate; the zero StreamMetadata if none
+// was saved
+func (s *Store) LoadStreamMetadata(ctx context.Context, aggregateID string) (StreamMetadata, error) {
+	out, err := s.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            makeMetadataKey(s.hashKey, s.rangeKey, aggregateID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return StreamMetadata{}, err
+	}
+
+	var metadata StreamMetadata
+	if av, ok := out.Item[maxAgeAttribute]; ok && av.N != nil {
+		v, err := strconv.ParseInt(*av.N, 10, 64)
+		if err != nil {
+			return StreamMetadata{}, err
+		}
+		metadata.MaxAge = time.Duration(v)
+	}
+	if av, ok := out.Item[maxCountAttribute]; ok && av.N != nil {
+		v, err := strconv.Atoi(*av.N)
+		if err != nil {
+			return StreamMetadata{}, err
+		}
+		metadata.MaxCount = v
+	}
+
+	return metadata, nil
+}
+
+// SaveStreamMetadata replaces the retention policy of the aggregate.  Saving the zero StreamMetadata
+// removes the policy.
+func (s *Store) SaveStreamMetadata(ctx context.Context, aggregateID string, metadata StreamMetadata) error {
+	key := makeMetadataKey(s.hashKey, s.rangeKey, aggregateID)
+
+	if metadata.IsZero() {
+		input := &dynamodb.DeleteItemInput{
+			TableName: aws.String(s.tableName),
+			Key:       key,
+		}
+		s.dump(input)
+
+		_, err := s.api.DeleteItemWithContext(ctx, input)
+		return err
+	}
+
+	item := key
+	if metadata.MaxAge > 0 {
+		item[maxAgeAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(int64(metadata.MaxAge), 10))}
+	}
+	if metadata.MaxCount > 0 {
+		item[maxCountAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(metadata.MaxCount))}
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item:      item,
+	}
+	s.dump(input)
+
+	_, err := s.api.PutItemWithContext(ctx, input)
+	return err
+}
+
+// recordsCommitTimes returns true if Save should record the commit time of each event
+func (s *Store) recordsCommitTimes() bool {
+	return s.commitTimes || s.retention
+}
+
+func makeCommittedKey(version int) string {
+	return committedPrefix + strconv.Itoa(version)
+}
+
+// addCommitTimes records the commit time of each record alongside the events in the partition item
+func addCommitTimes(input *dynamodb.UpdateItemInput, committed time.Time, records ...eventsource.Record) {
+	expr := *input.UpdateExpression
+	for _, record := range records {
+		nameRef := "#c" + makeKey(record.Version)
+		input.ExpressionAttributeNames[nameRef] = aws.String(makeCommittedKey(record.Version))
+		expr += ", " + nameRef + " = :committed"
+	}
+	input.ExpressionAttributeValues[":committed"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(committed.UnixNano(), 10))}
+	input.UpdateExpression = aws.String(expr)
+}
+
+// committedAt returns the commit time of the event within the item; false for events saved before
+// commit times were recorded
+func committedAt(item map[string]*dynamodb.AttributeValue, version int) (time.Time, bool) {
+	av, ok := item[makeCommittedKey(version)]
+	if !ok || av.N == nil {
+		return time.Time{}, false
+	}
+
+	v, err := strconv.ParseInt(*av.N, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, v), true
+}
+
+// retained returns false if the event within the item was committed before notBefore.  Events
+// without a commit time are always retained.
+func retained(item map[string]*dynamodb.AttributeValue, version int, notBefore time.Time) bool {
+	if notBefore.IsZero() {
+		return true
+	}
+
+	committed, ok := committedAt(item, version)
+	return !ok || !committed.Before(notBefore)
+}
+
+// latestVersion returns the version of the most recent event saved to the aggregate; 0 if there is
+// none
+func (s *Store) latestVersion(ctx context.Context, aggregateID string) (int, error) {
+	if s.strict {
+		return s.loadHead(ctx, aggregateID)
+	}
+
+	history, _, err := s.loadReverse(ctx, aggregateID, 0, 1, 0, loadOptions{})
+	if err != nil || len(history) == 0 {
+		return 0, err
+	}
+
+	return history[0].Version, nil
+}
+
+// retentionWindow returns the earliest version and commit time retained by the stream metadata given
+// the latest version of the aggregate
+func (s *Store) retentionWindow(metadata StreamMetadata, latest int) (int, time.Time) {
+	var (
+		fromVersion int
+		notBefore   time.Time
+	)
+
+	if metadata.MaxCount > 0 {
+		fromVersion = latest - metadata.MaxCount + 1
+	}
+	if metadata.MaxAge > 0 {
+		notBefore = s.now().Add(-metadata.MaxAge)
+	}
+
+	return fromVersion, notBefore
+}
+
+// applyRetention narrows the load to the events retained by the stream metadata of the aggregate
+func (s *Store) applyRetention(ctx context.Context, aggregateID string, fromVersion int, options loadOptions) (int, loadOptions, error) {
+	metadata, err := s.LoadStreamMetadata(ctx, aggregateID)
+	if err != nil || metadata.IsZero() {
+		return fromVersion, options, err
+	}
+
+	var latest int
+	if metadata.MaxCount > 0 {
+		if latest, err = s.latestVersion(ctx, aggregateID); err != nil {
+			return 0, options, err
+		}
+	}
+
+	from, notBefore := s.retentionWindow(metadata, latest)
+	if from > fromVersion {
+		fromVersion = from
+	}
+	options.notBefore = notBefore
+
+	return fromVersion, options, nil
+}
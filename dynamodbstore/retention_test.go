@@ -0,0 +1,262 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func TestAddCommitTimes(t *testing.T) {
+	records := []eventsource.Record{{Version: 1, Data: []byte("a")}, {Version: 2, Data: []byte("b")}}
+	input, err := makeUpdateItemInput("table", HashKey, RangeKey, 100, "abc", records...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	addCommitTimes(input, time.Unix(0, 123), records...)
+
+	if got, want := *input.UpdateExpression, "ADD #revision :one SET #_1 = :_1, #_2 = :_2, #c_1 = :committed, #c_2 = :committed"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *input.ExpressionAttributeNames["#c_2"], "@2"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *input.ExpressionAttributeValues[":committed"].N, "123"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestStore_LoadRetention(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	testCases := map[string]struct {
+		Metadata StreamMetadata
+		Expected []int
+	}{
+		"none": {
+			Expected: []int{1, 2, 3, 4, 5},
+		},
+		"max count": {
+			Metadata: StreamMetadata{MaxCount: 2},
+			Expected: []int{4, 5},
+		},
+		"max age": {
+			Metadata: StreamMetadata{MaxAge: time.Hour},
+			Expected: []int{3, 4, 5},
+		},
+		"both": {
+			Metadata: StreamMetadata{MaxAge: time.Hour, MaxCount: 1},
+			Expected: []int{5},
+		},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			api := &memoryTable{}
+			api.putEvents("abc", 2, now.Add(-2*time.Hour), 1, 2)
+			api.putEvents("abc", 2, now.Add(-time.Minute), 3, 4, 5)
+
+			store, err := New("blah", WithDynamoDB(api), WithEventPerItem(2), WithRetention(true))
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			store.now = func() time.Time { return now }
+
+			if err := store.SaveStreamMetadata(ctx, "abc", tc.Metadata); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			history, err := store.Load(ctx, "abc", 0, 0)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got := versionsOf(history); !reflect.DeepEqual(got, tc.Expected) {
+				t.Fatalf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestScavenger_Scavenge(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	setup := func(t *testing.T) (*memoryTable, *Store) {
+		api := &memoryTable{}
+		api.putEvents("abc", 2, now.Add(-2*time.Hour), 1, 2)
+		api.putEvents("abc", 2, now.Add(-time.Minute), 3, 4, 5)
+
+		store, err := New("blah", WithDynamoDB(api), WithEventPerItem(2))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		store.now = func() time.Time { return now }
+
+		if err := store.SaveStreamMetadata(ctx, "abc", StreamMetadata{MaxAge: time.Hour}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		return api, store
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		api, store := setup(t)
+
+		// partition 1 holds versions 2 and 3; 3 is retained
+		n, err := NewScavenger(store).Scavenge(ctx, "abc")
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := n, 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := api.deleted, []string{"abc|0"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		api, store := setup(t)
+
+		n, err := NewScavenger(store, WithExpiry(true)).Scavenge(ctx, "abc")
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := n, 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got := api.deleted; len(got) != 0 {
+			t.Fatalf("got %v; want no deletes", got)
+		}
+
+		item := api.items["abc|0"]
+		if got, want := *item[ExpiresAttribute].N, strconv.FormatInt(now.Unix(), 10); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *item[retainSinceAttribute].N, strconv.FormatInt(now.Add(-time.Hour).UnixNano(), 10); got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+
+	t.Run("latest retained", func(t *testing.T) {
+		api, store := setup(t)
+		store.now = func() time.Time { return now.Add(24 * time.Hour) }
+
+		if _, err := NewScavenger(store).Scavenge(ctx, "abc"); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := api.deleted, []string{"abc|0", "abc|1"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestExpired(t *testing.T) {
+	now := time.Now()
+	item := map[string]*dynamodb.AttributeValue{
+		"_1": {B: []byte("a")},
+		"_2": {B: []byte("b")},
+		"@1": {N: aws.String(strconv.FormatInt(now.Add(-time.Hour).UnixNano(), 10))},
+	}
+
+	testCases := map[string]struct {
+		FromVersion int
+		NotBefore   time.Time
+		Expected    bool
+	}{
+		"retained":       {},
+		"count":          {FromVersion: 3, Expected: true},
+		"partial count":  {FromVersion: 2},
+		"untimed events": {NotBefore: now},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			if got := expired(item, tc.FromVersion, tc.NotBefore); got != tc.Expected {
+				t.Fatalf("got %v; want %v", got, tc.Expected)
+			}
+		})
+	}
+}
+
+func TestScavenger_ScavengeConditions(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		var (
+			ctx = context.Background()
+			now = time.Now()
+			at  = now.Add(-2 * time.Hour)
+		)
+
+		store, err := New(tableName,
+			WithDynamoDB(api),
+			WithEventPerItem(2),
+			WithRetention(true),
+			WithClock(func() time.Time { return at }),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		aggregateID := "abc"
+		if err := store.Save(ctx, aggregateID, makeBatch(1, 2, 1)...); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		at = now
+		if err := store.Save(ctx, aggregateID, makeBatch(3, 3, 1)...); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := store.SaveStreamMetadata(ctx, aggregateID, StreamMetadata{MaxAge: time.Hour}); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		n, err := NewScavenger(store).Scavenge(ctx, aggregateID)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := n, 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		// marking an item already removed must not recreate it
+		item := map[string]*dynamodb.AttributeValue{
+			HashKey:  {S: aws.String(aggregateID)},
+			RangeKey: {N: aws.String("0")},
+		}
+		if err := store.expireItem(ctx, item, 3, time.Time{}, true); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		out, err := api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName:      aws.String(tableName),
+			Key:            item,
+			ConsistentRead: aws.Bool(true),
+		})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if len(out.Item) != 0 {
+			t.Fatalf("got %v; want no item", out.Item)
+		}
+
+		history, err := store.Load(ctx, aggregateID, 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := versionsOf(history), []int{3, 4, 5}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
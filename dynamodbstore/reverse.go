@@ -0,0 +1,205 @@
+package dynamodbstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// Cursor marks a position in the history of an aggregate when paging backwards.  Pages start with the
+// event immediately before the cursor; the zero Cursor starts from the most recent event.
+type Cursor int
+
+// Done returns true if no earlier events remain
+func (c Cursor) Done() bool {
+	return c == 1
+}
+
+// makeReverseQueryInput returns a query that reads the partitions holding events from fromVersion up to
+// the cursor, most recent first
+func (s *Store) makeReverseQueryInput(aggregateID string, fromVersion int, before Cursor, n int) *dynamodb.QueryInput {
+	to := -1
+	if before > 1 {
+		to = selectPartition(int(before)-1, s.eventsPerItem)
+	}
+
+	input, _ := makeQueryInput(s.tableName, s.hashKey, s.rangeKey, aggregateID, selectPartition(fromVersion, s.eventsPerItem), to)
+	input.ScanIndexForward = aws.Bool(false)
+	input.Limit = aws.Int64(int64((n+s.eventsPerItem-1)/s.eventsPerItem + 1))
+	return input
+}
+
+// reverseWindow returns the earliest version and commit time visible to reverse reads; the same events
+// LoadWith returns when WithRetention is enabled
+func (s *Store) reverseWindow(ctx context.Context, aggregateID string) (int, loadOptions, error) {
+	if !s.retention {
+		return 0, loadOptions{}, nil
+	}
+	return s.applyRetention(ctx, aggregateID, 0, loadOptions{})
+}
+
+// loadReverse returns, most recent first, up to n of the events before the cursor along with the
+// cursor of the next page.  Events before fromVersion or committed before options.notBefore are
+// omitted.
+func (s *Store) loadReverse(ctx context.Context, aggregateID string, before Cursor, n, fromVersion int, options loadOptions) (eventsource.History, Cursor, error) {
+	if n <= 0 || before.Done() {
+		return nil, before, nil
+	}
+
+	input := s.makeReverseQueryInput(aggregateID, fromVersion, before, n)
+	history := make(eventsource.History, 0, n)
+
+	for {
+		out, err := s.api.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, before, err
+		}
+
+		for _, item := range out.Items {
+			var records eventsource.History
+			for key, av := range item {
+				if !isKey(key) {
+					continue
+				}
+
+				version, err := versionFromKey(key)
+				if err != nil {
+					return nil, before, err
+				}
+				if before > 0 && version >= int(before) {
+					continue
+				}
+				if version < fromVersion || !retained(item, version, options.notBefore) {
+					continue
+				}
+
+				records = append(records, eventsource.Record{Version: version, Data: av.B})
+			}
+
+			sort.Sort(sort.Reverse(records))
+			for _, record := range records {
+				history = append(history, record)
+				if len(history) == n {
+					return history, Cursor(record.Version), nil
+				}
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return history, Cursor(1), nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// LoadLast returns the n most recent events of the aggregate, in version order, along with a cursor
+// that may be passed to LoadBefore to page further back.  As with LoadWith, events outside the
+// retention policy of the aggregate are omitted once WithRetention is enabled.
+func (s *Store) LoadLast(ctx context.Context, aggregateID string, n int) (eventsource.History, Cursor, error) {
+	return s.LoadBefore(ctx, aggregateID, 0, n)
+}
+
+// LoadBefore returns up to n of the events immediately before the cursor, in version order, along with
+// the cursor of the preceding page
+func (s *Store) LoadBefore(ctx context.Context, aggregateID string, cursor Cursor, n int) (eventsource.History, Cursor, error) {
+	fromVersion, options, err := s.reverseWindow(ctx, aggregateID)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	history, next, err := s.loadReverse(ctx, aggregateID, cursor, n, fromVersion, options)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	sort.Sort(history)
+	history, err = s.upcasters.Upcast(history...)
+	if err != nil {
+		return nil, cursor, err
+	}
+
+	return history, next, nil
+}
+
+// ReverseIterator walks the history of an aggregate from the most recent event backwards
+type ReverseIterator struct {
+	store       *Store
+	aggregateID string
+	pageSize    int
+	cursor      Cursor
+	page        eventsource.History
+	record      eventsource.Record
+	err         error
+}
+
+// IterateBackward returns an iterator over the events of the aggregate before the cursor, most recent
+// first, fetching pageSize events at a time.  Each page omits the events outside the retention policy
+// of the aggregate as of the time it is fetched.
+func (s *Store) IterateBackward(aggregateID string, cursor Cursor, pageSize int) *ReverseIterator {
+	if pageSize <= 0 {
+		pageSize = s.eventsPerItem
+	}
+
+	return &ReverseIterator{
+		store:       s,
+		aggregateID: aggregateID,
+		pageSize:    pageSize,
+		cursor:      cursor,
+	}
+}
+
+// Next advances the iterator; returns false once the first event has been returned or an error occurs
+func (it *ReverseIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.page) == 0 {
+		if it.cursor.Done() {
+			return false
+		}
+
+		fromVersion, options, err := it.store.reverseWindow(ctx, it.aggregateID)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		page, _, err := it.store.loadReverse(ctx, it.aggregateID, it.cursor, it.pageSize, fromVersion, options)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if page, err = it.store.upcasters.Upcast(page...); err != nil {
+			it.err = err
+			return false
+		}
+		if len(page) == 0 {
+			it.cursor = 1
+			return false
+		}
+		it.page = page
+	}
+
+	it.record, it.page = it.page[0], it.page[1:]
+	it.cursor = Cursor(it.record.Version)
+	return true
+}
+
+// Record returns the current event
+func (it *ReverseIterator) Record() eventsource.Record {
+	return it.record
+}
+
+// Cursor returns a cursor positioned before the current event; suitable for resuming iteration later
+func (it *ReverseIterator) Cursor() Cursor {
+	return it.cursor
+}
+
+// Err returns the error, if any, that stopped the iteration
+func (it *ReverseIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,143 @@
+package dynamodbstore
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+func newReverseStore() (*Store, *queryAPI) {
+	// partitions, most recent first, as returned with ScanIndexForward=false
+	api := &queryAPI{
+		pages: [][]map[string]*dynamodb.AttributeValue{
+			{{"_5": {B: []byte("e")}}},
+			{{"_3": {B: []byte("c")}, "_4": {B: []byte("d")}}},
+			{{"_1": {B: []byte("a")}, "_2": {B: []byte("b")}}},
+		},
+	}
+	return &Store{api: api, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey, eventsPerItem: 2}, api
+}
+
+func versionsOf(history eventsource.History) []int {
+	var versions []int
+	for _, record := range history {
+		versions = append(versions, record.Version)
+	}
+	return versions
+}
+
+func TestStore_LoadLast(t *testing.T) {
+	ctx := context.Background()
+	s, api := newReverseStore()
+
+	history, cursor, err := s.LoadLast(ctx, "abc", 2)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versionsOf(history), []int{4, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := cursor, Cursor(4); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got := *api.inputs[0].ScanIndexForward; got {
+		t.Fatalf("got %v; want false", got)
+	}
+	if got := api.inputs[0].ExpressionAttributeValues[":to"]; got != nil {
+		t.Fatalf("got %v; want no upper bound", got)
+	}
+
+	api.inputs = nil
+	history, cursor, err = s.LoadBefore(ctx, "abc", cursor, 2)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versionsOf(history), []int{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *api.inputs[0].ExpressionAttributeValues[":to"].N, "1"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	history, cursor, err = s.LoadBefore(ctx, "abc", cursor, 5)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versionsOf(history), []int{1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if !cursor.Done() {
+		t.Fatalf("got %v; want done", cursor)
+	}
+}
+
+func TestStore_IterateBackward(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newReverseStore()
+
+	var versions []int
+	it := s.IterateBackward("abc", 0, 2)
+	for it.Next(ctx) {
+		versions = append(versions, it.Record().Version)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versions, []int{5, 4, 3, 2, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if !it.Cursor().Done() {
+		t.Fatalf("got %v; want done", it.Cursor())
+	}
+}
+
+func TestStore_LoadLastRetention(t *testing.T) {
+	var (
+		ctx = context.Background()
+		now = time.Date(2019, time.June, 1, 0, 0, 0, 0, time.UTC)
+		api = &memoryTable{}
+	)
+	api.putEvents("abc", 2, now.Add(-2*time.Hour), 1, 2)
+	api.putEvents("abc", 2, now.Add(-time.Minute), 3, 4, 5)
+
+	store, err := New("blah", WithDynamoDB(api), WithEventPerItem(2), WithRetention(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	store.now = func() time.Time { return now }
+
+	if err := store.SaveStreamMetadata(ctx, "abc", StreamMetadata{MaxAge: time.Hour}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	history, cursor, err := store.LoadLast(ctx, "abc", 10)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versionsOf(history), []int{3, 4, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if !cursor.Done() {
+		t.Fatalf("got %v; want done", cursor)
+	}
+
+	if err := store.SaveStreamMetadata(ctx, "abc", StreamMetadata{MaxCount: 2}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	var versions []int
+	it := store.IterateBackward("abc", 0, 1)
+	for it.Next(ctx) {
+		versions = append(versions, it.Record().Version)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := versions, []int{5, 4}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,186 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// DefaultScavengeInterval is the time between passes of a Scavenger
+	DefaultScavengeInterval = time.Hour
+)
+
+// Scavenger removes partition items once every event within them falls outside the retention policy
+// of their aggregate.  The item holding the most recent event of an aggregate is never removed so
+// the version of the aggregate is retained.
+//
+// Items are first marked with ExpiresAttribute and the retention window, which exempts their removal
+// from the integrity check, and then deleted unless WithExpiry is set.
+type Scavenger struct {
+	store    *Store
+	interval time.Duration
+	expire   bool
+}
+
+// ScavengerOption represents a functional configuration of *Scavenger
+type ScavengerOption func(*Scavenger)
+
+// WithScavengeInterval specifies the time between passes; defaults to DefaultScavengeInterval
+func WithScavengeInterval(interval time.Duration) ScavengerOption {
+	return func(s *Scavenger) {
+		s.interval = interval
+	}
+}
+
+// WithExpiry leaves the deletion of expired items to dynamodb TTL rather than deleting them
+// directly.  Requires the table be created with WithTTL.  Deletion by TTL does not consume write
+// capacity but may lag by a day or more; Load hides the expired events in the meantime.
+func WithExpiry(enabled bool) ScavengerOption {
+	return func(s *Scavenger) {
+		s.expire = enabled
+	}
+}
+
+// NewScavenger returns a scavenger for the aggregates of the store
+func NewScavenger(store *Store, opts ...ScavengerOption) *Scavenger {
+	s := &Scavenger{
+		store:    store,
+		interval: DefaultScavengeInterval,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Run scavenges every aggregate with stream metadata each interval until the context is cancelled
+// or a pass fails
+func (s *Scavenger) Run(ctx context.Context) error {
+	for {
+		if err := s.ScavengeAll(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// ScavengeAll scavenges every aggregate with stream metadata.  The metadata items are found with a
+// scan of the table.
+func (s *Scavenger) ScavengeAll(ctx context.Context) error {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(s.store.tableName),
+		FilterExpression:     aws.String("begins_with(#key, :prefix)"),
+		ProjectionExpression: aws.String("#key"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key": aws.String(s.store.hashKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prefix": {S: aws.String(metadataPrefix)},
+		},
+	}
+
+	for {
+		out, err := s.store.api.ScanWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range out.Items {
+			av, ok := item[s.store.hashKey]
+			if !ok || av.S == nil || !strings.HasPrefix(*av.S, metadataPrefix) {
+				continue
+			}
+
+			if _, err := s.Scavenge(ctx, strings.TrimPrefix(*av.S, metadataPrefix)); err != nil {
+				return err
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// Scavenge removes, or marks for expiry, the partition items of the aggregate whose events have all
+// fallen outside its retention policy.  Returns the number of items removed or marked.
+func (s *Scavenger) Scavenge(ctx context.Context, aggregateID string) (int, error) {
+	metadata, err := s.store.LoadStreamMetadata(ctx, aggregateID)
+	if err != nil || metadata.IsZero() {
+		return 0, err
+	}
+
+	latest, err := s.store.latestVersion(ctx, aggregateID)
+	if err != nil {
+		return 0, err
+	}
+
+	last := selectPartition(latest, s.store.eventsPerItem)
+	if latest == 0 || last == 0 {
+		return 0, nil
+	}
+
+	fromVersion, notBefore := s.store.retentionWindow(metadata, latest)
+
+	input, err := makeQueryInput(s.store.tableName, s.store.hashKey, s.store.rangeKey, aggregateID, 0, last-1)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for {
+		out, err := s.store.api.QueryWithContext(ctx, input)
+		if err != nil {
+			return n, err
+		}
+
+		for _, item := range out.Items {
+			if !expired(item, fromVersion, notBefore) {
+				continue
+			}
+			if err := s.store.expireItem(ctx, item, fromVersion, notBefore, s.expire); err != nil {
+				return n, err
+			}
+			n++
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return n, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// expired returns true if every event within the item falls before fromVersion or was committed
+// before notBefore
+func expired(item map[string]*dynamodb.AttributeValue, fromVersion int, notBefore time.Time) bool {
+	var n int
+	for key := range item {
+		if !isKey(key) {
+			continue
+		}
+
+		version, err := versionFromKey(key)
+		if err != nil {
+			return false
+		}
+		if version >= fromVersion && retained(item, version, notBefore) {
+			return false
+		}
+		n++
+	}
+
+	return n > 0
+}
@@ -0,0 +1,115 @@
+package dynamodbstore
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+const (
+	// snapshotPrefix prefixes the hash key of snapshot items to keep them apart from aggregates
+	snapshotPrefix = reservedPrefix + "snapshot/"
+
+	// snapshotVersionAttribute holds the version of the last event folded into the snapshot
+	snapshotVersionAttribute = "version"
+
+	// snapshotDataAttribute holds the encoded aggregate
+	snapshotDataAttribute = "data"
+)
+
+// Snapshot holds the state of an aggregate as of Version
+type Snapshot struct {
+	// Version holds the version of the last event folded into the snapshot
+	Version int
+
+	// Data holds the encoded aggregate
+	Data []byte
+}
+
+// SnapshotStore saves and loads aggregate snapshots
+type SnapshotStore interface {
+	// LoadSnapshot returns the most recent snapshot of the aggregate; false if there is none
+	LoadSnapshot(ctx context.Context, aggregateID string) (Snapshot, bool, error)
+
+	// SaveSnapshot replaces the snapshot of the aggregate unless a more recent one exists
+	SaveSnapshot(ctx context.Context, aggregateID string, snapshot Snapshot) error
+}
+
+func makeSnapshotKey(hashKey, rangeKey, aggregateID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		hashKey:  {S: aws.String(snapshotPrefix + aggregateID)},
+		rangeKey: {N: aws.String(strconv.Itoa(0))},
+	}
+}
+
+// LoadSnapshot implements SnapshotStore; snapshots are held in the event table under reserved keys
+func (s *Store) LoadSnapshot(ctx context.Context, aggregateID string) (Snapshot, bool, error) {
+	out, err := s.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.tableName),
+		Key:            makeSnapshotKey(s.hashKey, s.rangeKey, aggregateID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+
+	version, ok := out.Item[snapshotVersionAttribute]
+	if !ok || version.N == nil {
+		return Snapshot{}, false, nil
+	}
+
+	v, err := strconv.Atoi(*version.N)
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+
+	var data []byte
+	if av, ok := out.Item[snapshotDataAttribute]; ok {
+		data = av.B
+	}
+
+	return Snapshot{Version: v, Data: data}, true, nil
+}
+
+// deleteSnapshot removes the snapshot of the aggregate, if any
+func (s *Store) deleteSnapshot(ctx context.Context, aggregateID string) error {
+	input := &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       makeSnapshotKey(s.hashKey, s.rangeKey, aggregateID),
+	}
+	s.dump(input)
+
+	_, err := s.api.DeleteItemWithContext(ctx, input)
+	return err
+}
+
+// SaveSnapshot implements SnapshotStore
+func (s *Store) SaveSnapshot(ctx context.Context, aggregateID string, snapshot Snapshot) error {
+	item := makeSnapshotKey(s.hashKey, s.rangeKey, aggregateID)
+	item[snapshotVersionAttribute] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(snapshot.Version))}
+	item[snapshotDataAttribute] = &dynamodb.AttributeValue{B: snapshot.Data}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(s.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#version) OR #version < :version"),
+		ExpressionAttributeNames: map[string]*string{
+			"#version": aws.String(snapshotVersionAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":version": item[snapshotVersionAttribute],
+		},
+	}
+	s.dump(input)
+
+	if _, err := s.api.PutItemWithContext(ctx, input); err != nil {
+		if IsConditionalCheckFailed(err) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
@@ -3,13 +3,14 @@ package dynamodbstore
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -32,6 +33,12 @@ const (
 	RangeKey = "partition"
 )
 
+const (
+	// maxProjectedEvents is the largest version range Load will enumerate in a projection expression;
+	// wider ranges fetch entire items
+	maxProjectedEvents = 100
+)
+
 var (
 	errInvalidKey       = errors.New("invalid event key")
 	errNoRecords        = errors.New("no records to save")
@@ -48,26 +55,34 @@ type Store struct {
 	eventsPerItem int
 	debug         bool
 	writer        io.Writer
+	strict        bool
+	deletion      bool
+	upcasters     *Upcasters
+	concurrency   int
+	retention     bool
+	commitTimes   bool
+	positions     bool
+	now           func() time.Time
 }
 
-// checkIdempotent will see if the specified records exist
+// checkIdempotent will see if the specified records exist; returns an ErrVersionConflict if they do not
 func (s *Store) checkIdempotent(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
 	if len(records) == 0 {
 		return nil
 	}
 
 	version := records[len(records)-1].Version
-	history, err := s.Load(ctx, aggregateID, 0, version)
+	history, err := s.load(ctx, aggregateID, 0, version, loadOptions{})
 	if err != nil {
 		return err
 	}
 	if len(history) < len(records) {
-		return errors.New(awsConditionalCheckFailed)
+		return newConflictError(aggregateID)
 	}
 
 	recent := history[len(history)-len(records):]
 	if !reflect.DeepEqual(recent, eventsource.History(records)) {
-		return errors.New(awsConditionalCheckFailed)
+		return newConflictError(aggregateID)
 	}
 
 	return nil
@@ -75,27 +90,58 @@ func (s *Store) checkIdempotent(ctx context.Context, aggregateID string, records
 
 // Save implements the eventsource.Store interface
 func (s *Store) Save(ctx context.Context, aggregateID string, records ...eventsource.Record) error {
+	return s.SaveWith(ctx, aggregateID, records)
+}
+
+// SaveWith saves the records to the aggregate using the per save options provided
+func (s *Store) SaveWith(ctx context.Context, aggregateID string, records []eventsource.Record, opts ...SaveOption) error {
 	if len(records) == 0 {
 		return nil
 	}
 
-	input, err := makeUpdateItemInput(s.tableName, s.hashKey, s.rangeKey, s.eventsPerItem, aggregateID, records...)
+	var options saveOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	reservations, err := uniqueReservations(options.reservations)
+	if err != nil {
+		return err
+	}
+	options.reservations = reservations
+
+	items, err := s.makeEventWrites(aggregateID, options.reservations, records...)
 	if err != nil {
 		return err
 	}
+	if options.idempotencyKey != "" {
+		items = append(items, makeIdempotencyWrite(s.tableName, s.hashKey, s.rangeKey, aggregateID, options.idempotencyKey, records...))
+	}
 
-	if s.debug {
-		encoder := json.NewEncoder(s.writer)
-		encoder.SetIndent("", "  ")
-		_ = encoder.Encode(input)
+	offset := len(items)
+	for _, r := range options.reservations {
+		items = append(items, makeReservationWrite(s.tableName, s.hashKey, s.rangeKey, aggregateID, r))
+	}
+	for i, entry := range options.outbox {
+		items = append(items, makeOutboxWrite(s.tableName, s.hashKey, s.rangeKey, aggregateID, records[0].Version, i, entry))
 	}
 
-	_, err = s.api.UpdateItem(input)
+	if err := checkTransactLimits(items...); err != nil {
+		return err
+	}
+
+	err = s.writePositioned(ctx, items, positionEntry{aggregateID: aggregateID, records: records})
 	if err != nil {
-		if v, ok := err.(awserr.Error); ok {
-			if v.Code() == awsConditionalCheckFailed {
-				return s.checkIdempotent(ctx, aggregateID, records...)
+		if IsConditionalCheckFailed(err) {
+			if r, ok := failedReservation(err, offset, options.reservations); ok {
+				return eventsource.NewError(nil, ErrConstraintViolation, "value, %v, for constraint, %v, is reserved by another aggregate", r.value, r.constraint)
 			}
+			return s.checkConflict(ctx, aggregateID, options, records...)
+		}
+		if isTransactionConflict(err) {
+			return newConflictError(aggregateID)
+		}
+		if v, ok := err.(awserr.Error); ok {
 			return eventsource.NewError(err, "Save failed. %v [%v]", v.Message(), v.Code())
 		}
 		return err
@@ -104,20 +150,155 @@ func (s *Store) Save(ctx context.Context, aggregateID string, records ...eventso
 	return nil
 }
 
+// makeEventWrites returns the writes that append the records to the aggregate: one update for each
+// partition item the records fall within, followed by the write to the head item when one is needed.
+// The commit time of each record is stored alongside it when recordsCommitTimes.  Each update is
+// checked against the dynamodb expression and item limits.
+func (s *Store) makeEventWrites(aggregateID string, reservations []reservation, records ...eventsource.Record) ([]*dynamodb.TransactWriteItem, error) {
+	inputs, err := makeUpdateItemInputs(s.tableName, s.hashKey, s.rangeKey, s.eventsPerItem, aggregateID, records...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		committed = s.now()
+		groups    = groupPartitions(s.eventsPerItem, records...)
+		items     = make([]*dynamodb.TransactWriteItem, 0, len(inputs)+1)
+	)
+	for i, input := range inputs {
+		if s.recordsCommitTimes() {
+			addCommitTimes(input, committed, groups[i]...)
+		}
+		update := makeUpdate(input)
+		if err := checkUpdateLimits(update); err != nil {
+			return nil, err
+		}
+		items = append(items, &dynamodb.TransactWriteItem{Update: update})
+	}
+
+	if s.strict {
+		if err := validateSequence(aggregateID, records...); err != nil {
+			return nil, err
+		}
+	}
+	if head := s.makeHeadWrite(aggregateID, reservations, records...); head != nil {
+		items = append(items, head)
+	}
+
+	return items, nil
+}
+
+// checkConflict is called when a conditional write fails; it returns nil if the records were
+// previously saved and an error describing the conflict otherwise
+func (s *Store) checkConflict(ctx context.Context, aggregateID string, options saveOptions, records ...eventsource.Record) error {
+	var err error
+	if options.idempotencyKey != "" {
+		err = s.checkIdempotencyKey(ctx, aggregateID, options.idempotencyKey, records...)
+	} else {
+		err = s.checkIdempotent(ctx, aggregateID, records...)
+	}
+	if err == nil || eventsource.ErrHasCode(err, ErrIdempotencyConflict) {
+		return err
+	}
+
+	if s.deletion {
+		if deleted := s.checkDeleted(ctx, aggregateID); deleted != nil {
+			return deleted
+		}
+	}
+	if !s.strict {
+		return err
+	}
+
+	head, err := s.loadHead(ctx, aggregateID)
+	if err != nil {
+		return err
+	}
+
+	return &VersionError{
+		AggregateID: aggregateID,
+		Expected:    head + 1,
+		Actual:      records[0].Version,
+	}
+}
+
 // Load satisfies the Store interface and retrieve events from dynamodb
 func (s *Store) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	return s.LoadWith(ctx, aggregateID, fromVersion, toVersion)
+}
+
+// LoadWith retrieves events from dynamodb using the per load options provided
+func (s *Store) LoadWith(ctx context.Context, aggregateID string, fromVersion, toVersion int, opts ...LoadOption) (eventsource.History, error) {
+	var options loadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if s.retention {
+		var err error
+		fromVersion, options, err = s.applyRetention(ctx, aggregateID, fromVersion, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	history, err := s.load(ctx, aggregateID, fromVersion, toVersion, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.upcasters.Upcast(history...)
+}
+
+// load retrieves the events, as stored, from dynamodb
+func (s *Store) load(ctx context.Context, aggregateID string, fromVersion, toVersion int, options loadOptions) (eventsource.History, error) {
 	from := selectPartition(fromVersion, s.eventsPerItem)
-	to := selectPartition(toVersion, s.eventsPerItem)
+	to := -1
+	if toVersion > 0 {
+		to = selectPartition(toVersion, s.eventsPerItem)
+	}
+
+	if s.concurrency > 1 {
+		if to < 0 && s.strict {
+			head, err := s.loadHead(ctx, aggregateID)
+			if err != nil {
+				return nil, err
+			}
+			to = selectPartition(head, s.eventsPerItem)
+		}
+		if to > from {
+			return s.loadParallel(ctx, aggregateID, fromVersion, toVersion, from, to, options)
+		}
+	}
+
 	input, err := makeQueryInput(s.tableName, s.hashKey, s.rangeKey, aggregateID, from, to)
 	if err != nil {
 		return nil, err
 	}
+	input.ConsistentRead = aws.Bool(!options.eventual)
+	if !options.timed() {
+		addProjection(input, fromVersion, toVersion)
+	}
+
+	history, err := s.query(ctx, input, fromVersion, toVersion, options)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Version < history[j].Version
+	})
+
+	return history, nil
+}
 
-	history := make(eventsource.History, 0, toVersion)
+// query returns the events between fromVersion and toVersion from every page of the query, omitting
+// events committed before options.notBefore or after options.notAfter
+func (s *Store) query(ctx context.Context, input *dynamodb.QueryInput, fromVersion, toVersion int, options loadOptions) (eventsource.History, error) {
+	var history eventsource.History
 
-	var startKey map[string]*dynamodb.AttributeValue
 	for {
-		out, err := s.api.Query(input)
+		out, err := s.api.QueryWithContext(ctx, input)
 		if err != nil {
 			return nil, err
 		}
@@ -144,6 +325,9 @@ func (s *Store) Load(ctx context.Context, aggregateID string, fromVersion, toVer
 				if toVersion > 0 && recordVersion > toVersion {
 					continue
 				}
+				if !retained(item, recordVersion, options.notBefore) || !committedBy(item, recordVersion, options.notAfter) {
+					continue
+				}
 
 				history = append(history, eventsource.Record{
 					Version: recordVersion,
@@ -152,19 +336,58 @@ func (s *Store) Load(ctx context.Context, aggregateID string, fromVersion, toVer
 			}
 		}
 
-		startKey = out.LastEvaluatedKey
-		if len(startKey) == 0 {
+		if len(out.LastEvaluatedKey) == 0 {
 			break
 		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
 	}
 
-	sort.Slice(history, func(i, j int) bool {
-		return history[i].Version < history[j].Version
-	})
-
 	return history, nil
 }
 
+// ListAggregates calls fn with the id of each aggregate in the table; the order is unspecified.
+// ListAggregates scans the entire table and is intended for rebuilds and catch up subscriptions.
+func (s *Store) ListAggregates(ctx context.Context, fn func(aggregateID string) error) error {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(s.tableName),
+		ProjectionExpression: aws.String("#key"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key": aws.String(s.hashKey),
+		},
+	}
+
+	// aggregates with more than one partition are returned once per partition
+	seen := map[string]struct{}{}
+	for {
+		out, err := s.api.ScanWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range out.Items {
+			av, ok := item[s.hashKey]
+			if !ok || av.S == nil || isReservedKey(*av.S) {
+				continue
+			}
+
+			aggregateID := *av.S
+			if _, ok := seen[aggregateID]; ok {
+				continue
+			}
+			seen[aggregateID] = struct{}{}
+
+			if err := fn(aggregateID); err != nil {
+				return err
+			}
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
 // New constructs a new dynamodb backed store
 func New(tableName string, opts ...Option) (*Store, error) {
 	store := &Store{
@@ -173,6 +396,7 @@ func New(tableName string, opts ...Option) (*Store, error) {
 		hashKey:       HashKey,
 		rangeKey:      RangeKey,
 		eventsPerItem: 100,
+		now:           time.Now,
 	}
 
 	for _, opt := range opts {
@@ -200,8 +424,8 @@ func validateInput(records ...eventsource.Record) error {
 		return errNoRecords
 	}
 
-	// version numbers may not duplicated
-	// TODO - do version numbers have to be sequential or is increasing satisfactory?
+	// version numbers may not duplicated; increasing is sufficient unless strict
+	// versioning is enabled, see WithStrictVersions
 	for i := len(records) - 2; i >= 0; i-- {
 		if records[i].Version == records[i+1].Version {
 			return errDuplicateVersion
@@ -211,6 +435,48 @@ func validateInput(records ...eventsource.Record) error {
 	return nil
 }
 
+// makeUpdateItemInputs sorts the records and returns one UpdateItemInput for each partition item the
+// records fall within, in partition order
+func makeUpdateItemInputs(tableName, hashKey, rangeKey string, eventsPerItem int, aggregateID string, records ...eventsource.Record) ([]*dynamodb.UpdateItemInput, error) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Version < records[j].Version
+	})
+
+	if err := validateInput(records...); err != nil {
+		return nil, err
+	}
+
+	var inputs []*dynamodb.UpdateItemInput
+	for _, group := range groupPartitions(eventsPerItem, records...) {
+		input, err := makeUpdateItemInput(tableName, hashKey, rangeKey, eventsPerItem, aggregateID, group...)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+	}
+
+	return inputs, nil
+}
+
+// groupPartitions splits the sorted records into runs that share a partition item
+func groupPartitions(eventsPerItem int, records ...eventsource.Record) [][]eventsource.Record {
+	var groups [][]eventsource.Record
+	for begin := 0; begin < len(records); {
+		partitionID := selectPartition(records[begin].Version, eventsPerItem)
+
+		end := begin + 1
+		for end < len(records) && selectPartition(records[end].Version, eventsPerItem) == partitionID {
+			end++
+		}
+
+		groups = append(groups, records[begin:end])
+		begin = end
+	}
+	return groups
+}
+
+// makeUpdateItemInput returns an update that appends the records to the partition item of the
+// first record; callers are expected to pass records from a single partition
 func makeUpdateItemInput(tableName, hashKey, rangeKey string, eventsPerItem int, aggregateID string, records ...eventsource.Record) (*dynamodb.UpdateItemInput, error) {
 	sort.Slice(records, func(i, j int) bool {
 		return records[i].Version < records[j].Version
@@ -266,34 +532,55 @@ func makeUpdateItemInput(tableName, hashKey, rangeKey string, eventsPerItem int,
 	return input, nil
 }
 
-// makeQueryInput
-//  - partition - fetch up to this partition number; 0 to fetch all partitions
+// makeQueryInput returns a query for the partitions of the aggregate from fromPartition through
+// toPartition inclusive; a negative toPartition fetches every partition from fromPartition onward
 func makeQueryInput(tableName, hashKey, rangeKey string, aggregateID string, fromPartition, toPartition int) (*dynamodb.QueryInput, error) {
 	input := &dynamodb.QueryInput{
 		TableName:      aws.String(tableName),
 		Select:         aws.String("ALL_ATTRIBUTES"),
 		ConsistentRead: aws.Bool(true),
 		ExpressionAttributeNames: map[string]*string{
-			"#key": aws.String(hashKey),
+			"#key":       aws.String(hashKey),
+			"#partition": aws.String(rangeKey),
 		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":key": {S: aws.String(aggregateID)},
+			":key":  {S: aws.String(aggregateID)},
+			":from": {N: aws.String(strconv.Itoa(fromPartition))},
 		},
 	}
 
-	if toPartition == 0 {
-		input.KeyConditionExpression = aws.String("#key = :key")
+	if toPartition < 0 {
+		input.KeyConditionExpression = aws.String("#key = :key AND #partition >= :from")
 
 	} else {
-		input.KeyConditionExpression = aws.String("#key = :key AND #partition >= :from AND #partition <= :to")
-		input.ExpressionAttributeNames["#partition"] = aws.String(rangeKey)
-		input.ExpressionAttributeValues[":from"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(fromPartition))}
+		input.KeyConditionExpression = aws.String("#key = :key AND #partition BETWEEN :from AND :to")
 		input.ExpressionAttributeValues[":to"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(toPartition))}
 	}
 
 	return input, nil
 }
 
+// addProjection limits the query to the event attributes between fromVersion and toVersion when the
+// range is bounded and small enough to enumerate
+func addProjection(input *dynamodb.QueryInput, fromVersion, toVersion int) {
+	if fromVersion < 1 {
+		fromVersion = 1
+	}
+	if toVersion <= 0 || toVersion < fromVersion || toVersion-fromVersion >= maxProjectedEvents {
+		return
+	}
+
+	names := make([]string, 0, toVersion-fromVersion+1)
+	for version := fromVersion; version <= toVersion; version++ {
+		name := "#v" + strconv.Itoa(version)
+		input.ExpressionAttributeNames[name] = aws.String(makeKey(version))
+		names = append(names, name)
+	}
+
+	input.Select = aws.String(dynamodb.SelectSpecificAttributes)
+	input.ProjectionExpression = aws.String(strings.Join(names, ", "))
+}
+
 func selectPartition(version, eventsPerItem int) int {
 	return version / eventsPerItem
 }
@@ -2,7 +2,14 @@ package dynamodbstore
 
 import (
 	"context"
+	"reflect"
+	"strconv"
 	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 func TestStore_CheckIdempotent(t *testing.T) {
@@ -12,3 +19,128 @@ func TestStore_CheckIdempotent(t *testing.T) {
 		t.Fatalf("got %v; want nil", err)
 	}
 }
+
+type scanAPI struct {
+	dynamodbiface.DynamoDBAPI
+	pages [][]map[string]*dynamodb.AttributeValue
+}
+
+func (s *scanAPI) ScanWithContext(_ aws.Context, input *dynamodb.ScanInput, _ ...request.Option) (*dynamodb.ScanOutput, error) {
+	page := 0
+	if input.ExclusiveStartKey != nil {
+		page, _ = strconv.Atoi(*input.ExclusiveStartKey["page"].N)
+	}
+
+	out := &dynamodb.ScanOutput{Items: s.pages[page]}
+	if next := page + 1; next < len(s.pages) {
+		out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"page": {N: aws.String(strconv.Itoa(next))}}
+	}
+	return out, nil
+}
+
+func TestStore_ListAggregates(t *testing.T) {
+	item := func(key string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{HashKey: {S: aws.String(key)}}
+	}
+	api := &scanAPI{
+		pages: [][]map[string]*dynamodb.AttributeValue{
+			{item("abc"), item(reservationPrefix + "email/a@example.com")},
+			{item("def"), item("abc"), item(outboxPrefix + "abc/1/0")},
+		},
+	}
+	s := &Store{api: api, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey}
+
+	var found []string
+	err := s.ListAggregates(context.Background(), func(aggregateID string) error {
+		found = append(found, aggregateID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := found, []string{"abc", "def"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+type queryAPI struct {
+	dynamodbiface.DynamoDBAPI
+	pages  [][]map[string]*dynamodb.AttributeValue
+	inputs []dynamodb.QueryInput
+}
+
+func (q *queryAPI) QueryWithContext(_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	q.inputs = append(q.inputs, *input)
+
+	page := 0
+	if input.ExclusiveStartKey != nil {
+		page, _ = strconv.Atoi(*input.ExclusiveStartKey["page"].N)
+	}
+
+	out := &dynamodb.QueryOutput{Items: q.pages[page]}
+	if next := page + 1; next < len(q.pages) {
+		out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"page": {N: aws.String(strconv.Itoa(next))}}
+	}
+	return out, nil
+}
+
+func TestStore_LoadWith(t *testing.T) {
+	api := &queryAPI{
+		pages: [][]map[string]*dynamodb.AttributeValue{
+			{{"_1": {B: []byte("a")}, "_2": {B: []byte("b")}}},
+			{{"_3": {B: []byte("c")}}},
+		},
+	}
+	s := &Store{api: api, tableName: "blah", hashKey: HashKey, rangeKey: RangeKey, eventsPerItem: 2}
+
+	t.Run("paging", func(t *testing.T) {
+		api.inputs = nil
+		history, err := s.Load(context.Background(), "abc", 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(history), 3; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := len(api.inputs), 2; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if api.inputs[1].ExclusiveStartKey == nil {
+			t.Fatalf("got nil; want ExclusiveStartKey on second page")
+		}
+		if got := api.inputs[0].ProjectionExpression; got != nil {
+			t.Fatalf("got %v; want nil for unbounded load", *got)
+		}
+		if got := *api.inputs[0].ConsistentRead; !got {
+			t.Fatalf("got %v; want true", got)
+		}
+	})
+
+	t.Run("projected", func(t *testing.T) {
+		api.inputs = nil
+		_, err := s.LoadWith(context.Background(), "abc", 2, 3, WithEventualConsistency())
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		input := api.inputs[0]
+		if got := *input.ConsistentRead; got {
+			t.Fatalf("got %v; want false", got)
+		}
+		if got, want := *input.ProjectionExpression, "#v2, #v3"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *input.ExpressionAttributeNames["#v3"], "_3"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *input.Select, dynamodb.SelectSpecificAttributes; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *input.ExpressionAttributeValues[":from"].N, "1"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if got, want := *input.ExpressionAttributeValues[":to"].N, "1"; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"os"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -285,3 +288,331 @@ func TestStore_LoadPartition(t *testing.T) {
 		}
 	})
 }
+
+func TestStore_SaveTransactionConflict(t *testing.T) {
+	api := &transactAPI{
+		err: awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled, please refer cancellation reasons for specific reasons [None, TransactionConflict]", nil),
+	}
+	store, err := New("blah", WithDynamoDB(api), WithStrictVersions(true))
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	err = store.Save(context.Background(), "abc", eventsource.Record{Version: 1, Data: []byte("a")})
+	if !eventsource.ErrHasCode(err, ErrVersionConflict) {
+		t.Fatalf("got %v; want %v", err, ErrVersionConflict)
+	}
+}
+
+func TestStore_SaveConcurrent(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+			WithStrictVersions(true),
+			WithDeletion(true),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// every writer races to save version 1; one wins and the rest see a version conflict
+		const writers = 10
+		var (
+			wg   sync.WaitGroup
+			errs = make(chan error, writers)
+		)
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- store.Save(ctx, "abc", eventsource.Record{Version: 1, Data: []byte(strconv.Itoa(i))})
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		saved := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				saved++
+			case !eventsource.ErrHasCode(err, ErrVersionConflict):
+				t.Fatalf("got %v; want %v", err, ErrVersionConflict)
+			}
+		}
+		if got, want := saved, 1; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestStore_SaveStrictVersions(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+			WithEventPerItem(2),
+			WithStrictVersions(true),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		aggregateID := "abc"
+		history := eventsource.History{
+			{
+				Version: 1,
+				Data:    []byte("a"),
+			},
+			{
+				Version: 2,
+				Data:    []byte("b"),
+			},
+			{
+				Version: 3,
+				Data:    []byte("c"),
+			},
+		}
+		err = store.Save(ctx, aggregateID, history...)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// gap between the head and the first record
+		err = store.Save(ctx, aggregateID, eventsource.Record{Version: 5, Data: []byte("e")})
+		if v, ok := err.(*VersionError); !ok || v.Expected != 4 || v.Actual != 5 {
+			t.Fatalf("got %v; want VersionError expecting 4", err)
+		}
+
+		// gap within the batch
+		err = store.Save(ctx, aggregateID, eventsource.Record{Version: 4, Data: []byte("d")}, eventsource.Record{Version: 6, Data: []byte("f")})
+		if v, ok := err.(*VersionError); !ok || v.Expected != 5 || v.Actual != 6 {
+			t.Fatalf("got %v; want VersionError expecting 5", err)
+		}
+
+		// next record in a new partition
+		err = store.Save(ctx, aggregateID, eventsource.Record{Version: 4, Data: []byte("d")})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// retry remains idempotent
+		err = store.Save(ctx, aggregateID, eventsource.Record{Version: 4, Data: []byte("d")})
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		found, err := store.Load(ctx, aggregateID, 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := len(found), 4; got != want {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestStore_SaveIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		aggregateID := "abc"
+		records := []eventsource.Record{
+			{
+				Version: 1,
+				Data:    []byte("a"),
+			},
+		}
+		err = store.SaveWith(ctx, aggregateID, records, WithIdempotencyKey("command-1"))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// retry with the same key and records
+		err = store.SaveWith(ctx, aggregateID, records, WithIdempotencyKey("command-1"))
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		// reuse the key with different records
+		changed := []eventsource.Record{
+			{
+				Version: 1,
+				Data:    []byte("b"),
+			},
+		}
+		err = store.SaveWith(ctx, aggregateID, changed, WithIdempotencyKey("command-1"))
+		if !eventsource.ErrHasCode(err, ErrIdempotencyConflict) {
+			t.Fatalf("got %v; want %v", err, ErrIdempotencyConflict)
+		}
+
+		// different key with conflicting records
+		err = store.SaveWith(ctx, aggregateID, changed, WithIdempotencyKey("command-2"))
+		if err == nil {
+			t.Fatalf("got nil; want not nil")
+		}
+
+		// reuse the key with records that fall within another partition
+		later := []eventsource.Record{
+			{
+				Version: 200,
+				Data:    []byte("c"),
+			},
+		}
+		err = store.SaveWith(ctx, aggregateID, later, WithIdempotencyKey("command-1"))
+		if !eventsource.ErrHasCode(err, ErrIdempotencyConflict) {
+			t.Fatalf("got %v; want %v", err, ErrIdempotencyConflict)
+		}
+
+		found, err := store.Load(ctx, aggregateID, 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := found, eventsource.History(records); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestStore_LoadUpcasters(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+			WithUpcasters(NewUpcasters(testSchemaReader,
+				Upcaster{EventType: "created", SchemaVersion: 1, Chain: []UpcastFunc{testUpcast('2')}},
+			)),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		aggregateID := "abc"
+		history := eventsource.History{
+			{Version: 1, Data: []byte("created:1:a")},
+			{Version: 2, Data: []byte("created:2:b")},
+		}
+		if err := store.Save(ctx, aggregateID, history...); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		found, err := store.Load(ctx, aggregateID, 0, 0)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		want := eventsource.History{
+			{Version: 1, Data: []byte("created:2:a+")},
+			{Version: 2, Data: []byte("created:2:b")},
+		}
+		if !reflect.DeepEqual(found, want) {
+			t.Fatalf("got %v; want %v", found, want)
+		}
+
+		// stored bytes are untouched so retries are still recognized as idempotent
+		if err := store.Save(ctx, aggregateID, history...); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	})
+}
+
+func TestStore_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		if _, ok, err := store.LoadSnapshot(ctx, "abc"); err != nil || ok {
+			t.Fatalf("got %v, %v; want false, nil", ok, err)
+		}
+
+		for _, snapshot := range []Snapshot{{Version: 2, Data: []byte("b")}, {Version: 1, Data: []byte("a")}} {
+			if err := store.SaveSnapshot(ctx, "abc", snapshot); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		}
+
+		// older snapshots never replace newer ones
+		snapshot, ok, err := store.LoadSnapshot(ctx, "abc")
+		if err != nil || !ok {
+			t.Fatalf("got %v, %v; want true, nil", ok, err)
+		}
+		if got, want := snapshot, (Snapshot{Version: 2, Data: []byte("b")}); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	})
+}
+
+func TestStore_LoadLastAcrossPartitions(t *testing.T) {
+	t.Parallel()
+
+	api := dynamodbOrSkip(t)
+
+	TempTable(t, api, func(tableName string) {
+		ctx := context.Background()
+		store, err := New(tableName,
+			WithDynamoDB(api),
+			WithEventPerItem(2),
+			WithStrictVersions(true),
+		)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+
+		aggregateID := "abc"
+		for version := 1; version <= 5; version++ {
+			if err := store.Save(ctx, aggregateID, eventsource.Record{Version: version, Data: []byte{byte(version)}}); err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		}
+
+		history, cursor, err := store.LoadLast(ctx, aggregateID, 3)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := versionsOf(history), []int{3, 4, 5}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+
+		history, cursor, err = store.LoadBefore(ctx, aggregateID, cursor, 3)
+		if err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if got, want := versionsOf(history), []int{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+		if !cursor.Done() {
+			t.Fatalf("got %v; want done", cursor)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package subscribe
+
+import (
+	"context"
+	"sync"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// HistoryReader provides the history replayed by a CatchUp subscription; satisfied by
+// *dynamodbstore.Store
+type HistoryReader interface {
+	// ListAggregates calls fn with the id of each aggregate
+	ListAggregates(ctx context.Context, fn func(aggregateID string) error) error
+
+	// Load returns the history of the aggregate between the versions specified
+	Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error)
+}
+
+// CatchUp delivers the complete history of every aggregate and then switches to the live stream
+// without gaps or duplicates.  Stream records received while history is replayed are held, neither
+// delivered nor checkpointed, until the replay completes.
+//
+// History is loaded through the HistoryReader, which upcasts events when it is a Store configured
+// with WithUpcasters; pass the same upcasters to the subscription, see WithUpcasters, so records
+// read from the stream are delivered in the same schema.
+//
+// Each (aggregate, version) is delivered at least once and the records of each aggregate are delivered
+// in version order; the handler is never called concurrently.  Records that arrive ahead of their
+// predecessors, e.g. from different partitions of the same aggregate, cause the missing versions to be
+// loaded from the HistoryReader.  The version most recently delivered for every aggregate is retained
+// in memory.
+type CatchUp struct {
+	history   HistoryReader
+	streamARN string
+	handler   Handler
+	opts      []Option
+
+	mutex     sync.Mutex
+	live      chan struct{}
+	delivered map[string]int
+}
+
+// NewCatchUp constructs a CatchUp subscription.  Options are passed to the underlying Subscriber
+// which, by default, reads from the trim horizon so that no record committed while history is being
+// replayed can be missed.
+func NewCatchUp(history HistoryReader, streamARN string, handler Handler, opts ...Option) *CatchUp {
+	return &CatchUp{
+		history:   history,
+		streamARN: streamARN,
+		handler:   handler,
+		opts:      opts,
+		live:      make(chan struct{}),
+		delivered: map[string]int{},
+	}
+}
+
+// Run replays history and then follows the stream until the context is cancelled or an error occurs
+func (c *CatchUp) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	subscriber, err := New(c.streamARN, c.receive, c.opts...)
+	if err != nil {
+		return err
+	}
+
+	// start reading the stream before history so that nothing committed during the replay is missed
+	errs := make(chan error, 1)
+	go func() {
+		errs <- subscriber.Run(ctx)
+	}()
+
+	if err := c.replay(ctx); err != nil {
+		cancel()
+		<-errs
+		return err
+	}
+
+	return <-errs
+}
+
+// replay delivers the history of each aggregate and then releases the records held from the stream
+func (c *CatchUp) replay(ctx context.Context) error {
+	err := c.history.ListAggregates(ctx, func(aggregateID string) error {
+		history, err := c.history.Load(ctx, aggregateID, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		return c.deliver(ctx, aggregateID, history)
+	})
+	if err != nil {
+		return err
+	}
+
+	close(c.live)
+	return nil
+}
+
+// receive is the Handler for the underlying Subscriber.  Records are held until the replay completes
+// so they are only checkpointed once delivered.
+func (c *CatchUp) receive(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.live:
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.deliver(ctx, aggregateID, records)
+}
+
+// deliver passes records the handler has not yet seen to the handler, first filling any gap between
+// the last version delivered and the records.  Must be called with the mutex held.
+func (c *CatchUp) deliver(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+	last := c.delivered[aggregateID]
+
+	var fresh []eventsource.Record
+	for _, record := range records {
+		if record.Version > last {
+			fresh = append(fresh, record)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	if first := fresh[0].Version; first > last+1 {
+		missing, err := c.history.Load(ctx, aggregateID, last+1, first-1)
+		if err != nil {
+			return err
+		}
+
+		var filled []eventsource.Record
+		for _, record := range missing {
+			if record.Version > last && record.Version < first {
+				filled = append(filled, record)
+			}
+		}
+		fresh = append(filled, fresh...)
+	}
+
+	if err := c.handler(ctx, aggregateID, fresh); err != nil {
+		return err
+	}
+
+	c.delivered[aggregateID] = fresh[len(fresh)-1].Version
+	return nil
+}
@@ -0,0 +1,177 @@
+package subscribe
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+type memoryHistory struct {
+	listed []string
+	events map[string]eventsource.History
+}
+
+func (m *memoryHistory) ListAggregates(ctx context.Context, fn func(aggregateID string) error) error {
+	for _, aggregateID := range m.listed {
+		if err := fn(aggregateID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryHistory) Load(ctx context.Context, aggregateID string, fromVersion, toVersion int) (eventsource.History, error) {
+	var history eventsource.History
+	for _, record := range m.events[aggregateID] {
+		if record.Version >= fromVersion && (toVersion == 0 || record.Version <= toVersion) {
+			history = append(history, record)
+		}
+	}
+	return history, nil
+}
+
+func TestCatchUp_Run(t *testing.T) {
+	history := &memoryHistory{
+		listed: []string{"abc"},
+		events: map[string]eventsource.History{
+			"abc": {{Version: 1, Data: []byte("abc-1")}, {Version: 2, Data: []byte("abc-2")}},
+			"def": {{Version: 1, Data: []byte("def-1")}, {Version: 2, Data: []byte("def-2")}},
+		},
+	}
+	streams := &fakeStream{
+		shards: []*dynamodbstreams.Shard{
+			makeShard("shard", ""),
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"shard": {
+				makeRecord("1", "abc", 2), // already replayed
+				makeRecord("2", "abc", 3),
+				makeRecord("3", "def", 2), // def-1 was saved before the stream was read
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	type delivery struct {
+		AggregateID string
+		Versions    []int
+	}
+	var deliveries []delivery
+
+	c := NewCatchUp(history, "arn", func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		d := delivery{AggregateID: aggregateID}
+		for _, record := range records {
+			d.Versions = append(d.Versions, record.Version)
+		}
+		deliveries = append(deliveries, d)
+		if aggregateID == "def" {
+			cancel()
+		}
+		return nil
+	},
+		WithDynamoDBStreams(streams),
+		WithDescribeInterval(time.Millisecond),
+		WithPollInterval(time.Millisecond),
+	)
+
+	if err := c.Run(ctx); err != context.Canceled {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+
+	want := []delivery{
+		{AggregateID: "abc", Versions: []int{1, 2}},
+		{AggregateID: "abc", Versions: []int{3}},
+		{AggregateID: "def", Versions: []int{1, 2}},
+	}
+	if got := deliveries; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+// orderedCheckpointer records, for each checkpoint, how many records had been delivered
+type orderedCheckpointer struct {
+	*memoryCheckpointer
+	delivered *int
+	saved     []int
+}
+
+func (o *orderedCheckpointer) Save(ctx context.Context, shardID, sequenceNumber string) error {
+	o.saved = append(o.saved, *o.delivered)
+	return o.memoryCheckpointer.Save(ctx, shardID, sequenceNumber)
+}
+
+func TestCatchUp_CheckpointAfterDelivery(t *testing.T) {
+	history := &memoryHistory{
+		listed: []string{"abc"},
+		events: map[string]eventsource.History{
+			"abc": {{Version: 1, Data: []byte("abc-1")}},
+		},
+	}
+	streams := &fakeStream{
+		shards: []*dynamodbstreams.Shard{
+			makeShard("shard", ""),
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"shard": {
+				makeRecord("1", "abc", 2),
+			},
+		},
+	}
+	upcasters := dynamodbstore.NewUpcasters(
+		func(data []byte) (string, int, error) { return "event", 0, nil },
+		dynamodbstore.Upcaster{
+			EventType: "event",
+			Chain: []dynamodbstore.UpcastFunc{
+				func(data []byte) ([]byte, error) { return append(data, "+v1"...), nil },
+			},
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var (
+		delivered    int
+		data         []string
+		checkpointer = &orderedCheckpointer{memoryCheckpointer: newMemoryCheckpointer(), delivered: &delivered}
+	)
+	c := NewCatchUp(history, "arn", func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		for _, record := range records {
+			data = append(data, string(record.Data))
+		}
+		delivered += len(records)
+		if delivered == 2 {
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+			}()
+		}
+		return nil
+	},
+		WithDynamoDBStreams(streams),
+		WithCheckpointer(checkpointer),
+		WithUpcasters(upcasters),
+		WithDescribeInterval(time.Millisecond),
+		WithPollInterval(time.Millisecond),
+	)
+
+	if err := c.Run(ctx); err != context.Canceled {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+
+	// the stream record is checkpointed only once delivered after the replay
+	if got, want := checkpointer.saved, []int{2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	// history is loaded as stored by memoryHistory; the stream record is upcast
+	if got, want := data, []string{"abc-1", "abc-2+v1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
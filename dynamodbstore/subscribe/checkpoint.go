@@ -0,0 +1,195 @@
+package subscribe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	// GroupKey is the hash key of the checkpoint table; holds the name of the consumer group
+	GroupKey = "group"
+
+	// ShardKey is the range key of the checkpoint table; holds the shard id
+	ShardKey = "shard"
+
+	sequenceAttribute = "sequence"
+	finishedAttribute = "finished"
+)
+
+// Checkpoint records the progress of a subscription through a shard
+type Checkpoint struct {
+	// SequenceNumber of the last record processed; blank if none have been
+	SequenceNumber string
+
+	// Finished is true once every record in a closed shard has been processed
+	Finished bool
+}
+
+// Checkpointer persists the progress of a subscription through each shard of a stream
+type Checkpointer interface {
+	// Load returns the checkpoint for the shard; the zero Checkpoint if none has been saved
+	Load(ctx context.Context, shardID string) (Checkpoint, error)
+
+	// Save records that every record up to and including sequenceNumber has been processed
+	Save(ctx context.Context, shardID, sequenceNumber string) error
+
+	// Finish records that every record in the closed shard has been processed
+	Finish(ctx context.Context, shardID string) error
+}
+
+// memoryCheckpointer holds checkpoints in memory; progress is lost on restart
+type memoryCheckpointer struct {
+	mutex       sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+func newMemoryCheckpointer() *memoryCheckpointer {
+	return &memoryCheckpointer{
+		checkpoints: map[string]Checkpoint{},
+	}
+}
+
+func (m *memoryCheckpointer) Load(ctx context.Context, shardID string) (Checkpoint, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.checkpoints[shardID], nil
+}
+
+func (m *memoryCheckpointer) Save(ctx context.Context, shardID, sequenceNumber string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cp := m.checkpoints[shardID]
+	cp.SequenceNumber = sequenceNumber
+	m.checkpoints[shardID] = cp
+	return nil
+}
+
+func (m *memoryCheckpointer) Finish(ctx context.Context, shardID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	cp := m.checkpoints[shardID]
+	cp.Finished = true
+	m.checkpoints[shardID] = cp
+	return nil
+}
+
+// CheckpointTable is a Checkpointer backed by a dynamodb table.  A single table may be shared by many
+// consumer groups.
+type CheckpointTable struct {
+	api       dynamodbiface.DynamoDBAPI
+	tableName string
+	group     string
+}
+
+// NewCheckpointTable returns a Checkpointer that stores the checkpoints of the consumer group in the
+// specified table.  See MakeCreateCheckpointTableInput for the table definition.
+func NewCheckpointTable(api dynamodbiface.DynamoDBAPI, tableName, group string) *CheckpointTable {
+	return &CheckpointTable{
+		api:       api,
+		tableName: tableName,
+		group:     group,
+	}
+}
+
+func (c *CheckpointTable) key(shardID string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		GroupKey: {S: aws.String(c.group)},
+		ShardKey: {S: aws.String(shardID)},
+	}
+}
+
+// Load implements Checkpointer
+func (c *CheckpointTable) Load(ctx context.Context, shardID string) (Checkpoint, error) {
+	out, err := c.api.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(c.tableName),
+		Key:            c.key(shardID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("unable to load checkpoint for shard, %v - %v", shardID, err)
+	}
+
+	var cp Checkpoint
+	if av, ok := out.Item[sequenceAttribute]; ok && av.S != nil {
+		cp.SequenceNumber = *av.S
+	}
+	if av, ok := out.Item[finishedAttribute]; ok && av.BOOL != nil {
+		cp.Finished = *av.BOOL
+	}
+	return cp, nil
+}
+
+// Save implements Checkpointer
+func (c *CheckpointTable) Save(ctx context.Context, shardID, sequenceNumber string) error {
+	_, err := c.api.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(c.tableName),
+		Key:              c.key(shardID),
+		UpdateExpression: aws.String("SET #sequence = :sequence"),
+		ExpressionAttributeNames: map[string]*string{
+			"#sequence": aws.String(sequenceAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":sequence": {S: aws.String(sequenceNumber)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to save checkpoint for shard, %v - %v", shardID, err)
+	}
+	return nil
+}
+
+// Finish implements Checkpointer
+func (c *CheckpointTable) Finish(ctx context.Context, shardID string) error {
+	_, err := c.api.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:        aws.String(c.tableName),
+		Key:              c.key(shardID),
+		UpdateExpression: aws.String("SET #finished = :finished"),
+		ExpressionAttributeNames: map[string]*string{
+			"#finished": aws.String(finishedAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":finished": {BOOL: aws.Bool(true)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to finish checkpoint for shard, %v - %v", shardID, err)
+	}
+	return nil
+}
+
+// MakeCreateCheckpointTableInput returns the definition of a checkpoint table suitable for use with
+// NewCheckpointTable
+func MakeCreateCheckpointTableInput(tableName string) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+		TableName:   aws.String(tableName),
+		AttributeDefinitions: []*dynamodb.AttributeDefinition{
+			{
+				AttributeName: aws.String(GroupKey),
+				AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+			},
+			{
+				AttributeName: aws.String(ShardKey),
+				AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+			},
+		},
+		KeySchema: []*dynamodb.KeySchemaElement{
+			{
+				AttributeName: aws.String(GroupKey),
+				KeyType:       aws.String(dynamodb.KeyTypeHash),
+			},
+			{
+				AttributeName: aws.String(ShardKey),
+				KeyType:       aws.String(dynamodb.KeyTypeRange),
+			},
+		},
+	}
+}
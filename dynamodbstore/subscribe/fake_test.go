@@ -0,0 +1,229 @@
+package subscribe
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// fakeDynamoDB is an in-process, single table dynamodb that understands the subset of condition and
+// update expressions used by this package
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	mutex sync.Mutex
+	keys  []string
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func newFakeDynamoDB(keys ...string) *fakeDynamoDB {
+	return &fakeDynamoDB{
+		keys:  keys,
+		items: map[string]map[string]*dynamodb.AttributeValue{},
+	}
+}
+
+func (f *fakeDynamoDB) id(key map[string]*dynamodb.AttributeValue) string {
+	var parts []string
+	for _, k := range f.keys {
+		parts = append(parts, key[k].String())
+	}
+	return strings.Join(parts, "|")
+}
+
+func (f *fakeDynamoDB) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return &dynamodb.GetItemOutput{Item: f.items[f.id(input.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItemWithContext(_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option) (*dynamodb.PutItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	id := f.id(input.Item)
+	if !evalCondition(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, f.items[id]) {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	f.items[id] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	id := f.id(input.Key)
+	item := f.items[id]
+	if !evalCondition(input.ConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, item) {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	updated := map[string]*dynamodb.AttributeValue{}
+	for k, v := range item {
+		updated[k] = v
+	}
+	for k, v := range input.Key {
+		updated[k] = v
+	}
+	applyUpdate(*input.UpdateExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, item, updated)
+	f.items[id] = updated
+
+	out := &dynamodb.UpdateItemOutput{}
+	if aws.StringValue(input.ReturnValues) == dynamodb.ReturnValueAllNew {
+		out.Attributes = updated
+	}
+	return out, nil
+}
+
+func (f *fakeDynamoDB) QueryWithContext(_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option) (*dynamodb.QueryOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var ids []string
+	for id, item := range f.items {
+		if evalCondition(input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues, item) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	out := &dynamodb.QueryOutput{}
+	for _, id := range ids {
+		out.Items = append(out.Items, f.items[id])
+	}
+	return out, nil
+}
+
+func tokenize(expr string) []string {
+	for _, sym := range []string{"(", ")", ",", "=", "+"} {
+		expr = strings.Replace(expr, sym, " "+sym+" ", -1)
+	}
+	return strings.Fields(expr)
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+	names  map[string]*string
+	values map[string]*dynamodb.AttributeValue
+	item   map[string]*dynamodb.AttributeValue
+}
+
+func (p *exprParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *exprParser) operand(tok string) *dynamodb.AttributeValue {
+	if strings.HasPrefix(tok, "#") {
+		return p.item[*p.names[tok]]
+	}
+	return p.values[tok]
+}
+
+func (p *exprParser) expr() bool {
+	v := p.term()
+	for p.peek() == "OR" {
+		p.next()
+		r := p.term()
+		v = v || r
+	}
+	return v
+}
+
+func (p *exprParser) term() bool {
+	v := p.factor()
+	for p.peek() == "AND" {
+		p.next()
+		r := p.factor()
+		v = v && r
+	}
+	return v
+}
+
+func (p *exprParser) factor() bool {
+	switch tok := p.next(); tok {
+	case "(":
+		v := p.expr()
+		p.next() // )
+		return v
+	case "attribute_exists", "attribute_not_exists":
+		p.next() // (
+		_, ok := p.item[*p.names[p.next()]]
+		p.next() // )
+		return ok == (tok == "attribute_exists")
+	default:
+		left := p.operand(tok)
+		if op := p.next(); op != "=" {
+			panic(fmt.Sprintf("unsupported operator, %v", op))
+		}
+		return equal(left, p.operand(p.next()))
+	}
+}
+
+func equal(a, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if a.N != nil && b.N != nil {
+		x, _ := strconv.ParseFloat(*a.N, 64)
+		y, _ := strconv.ParseFloat(*b.N, 64)
+		return x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func evalCondition(expr *string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) bool {
+	if expr == nil {
+		return true
+	}
+	p := &exprParser{tokens: tokenize(*expr), names: names, values: values, item: item}
+	return p.expr()
+}
+
+// applyUpdate evaluates SET and REMOVE clauses against item and writes the results to updated
+func applyUpdate(expr string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item, updated map[string]*dynamodb.AttributeValue) {
+	p := &exprParser{tokens: tokenize(expr), names: names, values: values, item: item}
+	for clause := p.next(); clause != ""; {
+		for {
+			name := *names[p.next()]
+			if clause == "REMOVE" {
+				delete(updated, name)
+			} else {
+				p.next() // =
+				v := p.operand(p.next())
+				if p.peek() == "+" {
+					p.next()
+					x, _ := strconv.ParseInt(*v.N, 10, 64)
+					y, _ := strconv.ParseInt(*p.operand(p.next()).N, 10, 64)
+					v = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(x+y, 10))}
+				}
+				updated[name] = v
+			}
+
+			if p.peek() != "," {
+				break
+			}
+			p.next()
+		}
+		clause = p.next()
+	}
+}
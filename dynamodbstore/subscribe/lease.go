@@ -0,0 +1,479 @@
+package subscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+const (
+	ownerAttribute    = "owner"
+	counterAttribute  = "counter"
+	transferAttribute = "transfer"
+)
+
+var (
+	errLeaseLost = errors.New("lease lost to another worker")
+)
+
+// Lease represents the right of a single worker within a consumer group to read a shard.  Leases
+// are stored alongside the checkpoint of the shard so progress survives a change of owner.
+type Lease struct {
+	Checkpoint
+
+	// ShardID holds the id of the leased shard
+	ShardID string
+
+	// Owner holds the id of the worker holding the lease; blank if the lease is free
+	Owner string
+
+	// Counter is incremented each time the lease is taken or renewed
+	Counter int64
+
+	// Transfer holds the id of the worker waiting to take over the lease; blank unless a transfer
+	// has been requested
+	Transfer string
+}
+
+// observation records when a change in lease counter was last seen
+type observation struct {
+	counter int64
+	at      time.Time
+}
+
+// LeaseCoordinator distributes the shards of a stream among the workers of a consumer group.  In
+// the spirit of the KCL, owners heartbeat their leases by incrementing a counter and a lease whose
+// counter has not changed within the lease duration, as measured by the observing worker's clock,
+// is considered expired and may be taken by any worker.  Workers holding fewer than their fair share
+// of leases steal, one at a time, from the most loaded worker.
+//
+// Leases are stolen by transfer rather than taken outright so a shard is never read by two workers
+// at once.  The stealing worker records itself as the transfer of the lease; the owner learns of the
+// transfer when it next renews, stops reading the shard, checkpoints, and releases the lease; only
+// then does the stealing worker take it.  A transfer the owner never completes is abandoned once the
+// lease expires.
+//
+// LeaseCoordinator satisfies Checkpointer; checkpoints may only be written by the owner of the lease.
+type LeaseCoordinator struct {
+	table         *CheckpointTable
+	workerID      string
+	leaseDuration time.Duration
+	now           func() time.Time
+
+	mutex    sync.Mutex
+	held     map[string]struct{}
+	observed map[string]observation
+}
+
+// LeaseOption represents a functional configuration of *LeaseCoordinator
+type LeaseOption func(*LeaseCoordinator)
+
+// WithLeaseDuration specifies how long a lease may go without being renewed before other workers
+// consider it expired; defaults to 30s.  Leases are renewed each time the Subscriber describes the
+// stream so the duration should be several multiples of the describe interval.
+func WithLeaseDuration(d time.Duration) LeaseOption {
+	return func(c *LeaseCoordinator) {
+		c.leaseDuration = d
+	}
+}
+
+// NewLeaseCoordinator constructs a LeaseCoordinator for the worker that stores leases in the specified
+// table.  The table uses the same definition as the checkpoint table, see MakeCreateCheckpointTableInput.
+func NewLeaseCoordinator(api dynamodbiface.DynamoDBAPI, tableName, group, workerID string, opts ...LeaseOption) *LeaseCoordinator {
+	c := &LeaseCoordinator{
+		table:         NewCheckpointTable(api, tableName, group),
+		workerID:      workerID,
+		leaseDuration: 30 * time.Second,
+		now:           time.Now,
+		held:          map[string]struct{}{},
+		observed:      map[string]observation{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Leases returns every lease held by the consumer group
+func (c *LeaseCoordinator) Leases(ctx context.Context) ([]Lease, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(c.table.tableName),
+		ConsistentRead:         aws.Bool(true),
+		KeyConditionExpression: aws.String("#group = :group"),
+		ExpressionAttributeNames: map[string]*string{
+			"#group": aws.String(GroupKey),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":group": {S: aws.String(c.table.group)},
+		},
+	}
+
+	var leases []Lease
+	for {
+		out, err := c.table.api.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list leases for group, %v - %v", c.table.group, err)
+		}
+
+		for _, item := range out.Items {
+			leases = append(leases, leaseFromItem(item))
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return leases, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func leaseFromItem(item map[string]*dynamodb.AttributeValue) Lease {
+	var lease Lease
+	if av, ok := item[ShardKey]; ok && av.S != nil {
+		lease.ShardID = *av.S
+	}
+	if av, ok := item[ownerAttribute]; ok && av.S != nil {
+		lease.Owner = *av.S
+	}
+	if av, ok := item[counterAttribute]; ok && av.N != nil {
+		lease.Counter, _ = strconv.ParseInt(*av.N, 10, 64)
+	}
+	if av, ok := item[sequenceAttribute]; ok && av.S != nil {
+		lease.SequenceNumber = *av.S
+	}
+	if av, ok := item[finishedAttribute]; ok && av.BOOL != nil {
+		lease.Finished = *av.BOOL
+	}
+	if av, ok := item[transferAttribute]; ok && av.S != nil {
+		lease.Transfer = *av.S
+	}
+	return lease
+}
+
+// createLease adds a free lease for the shard unless one already exists
+func (c *LeaseCoordinator) createLease(ctx context.Context, shardID string) error {
+	item := c.table.key(shardID)
+	item[counterAttribute] = &dynamodb.AttributeValue{N: aws.String("0")}
+
+	_, err := c.table.api.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(c.table.tableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(#shard)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#shard": aws.String(ShardKey),
+		},
+	})
+	if err != nil && !dynamodbstore.IsConditionalCheckFailed(err) {
+		return fmt.Errorf("unable to create lease for shard, %v - %v", shardID, err)
+	}
+	return nil
+}
+
+// takeLease claims the lease, abandoning any transfer in progress, provided it has not changed since
+// it was read; returns false if the lease was claimed or renewed by another worker in the meantime
+func (c *LeaseCoordinator) takeLease(ctx context.Context, lease Lease) (bool, error) {
+	_, err := c.table.api.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(c.table.tableName),
+		Key:                 c.table.key(lease.ShardID),
+		UpdateExpression:    aws.String("SET #owner = :owner, #counter = #counter + :one REMOVE #transfer"),
+		ConditionExpression: aws.String("#counter = :counter"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner":    aws.String(ownerAttribute),
+			"#counter":  aws.String(counterAttribute),
+			"#transfer": aws.String(transferAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner":   {S: aws.String(c.workerID)},
+			":one":     {N: aws.String("1")},
+			":counter": {N: aws.String(strconv.FormatInt(lease.Counter, 10))},
+		},
+	})
+	if err != nil {
+		if dynamodbstore.IsConditionalCheckFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to take lease for shard, %v - %v", lease.ShardID, err)
+	}
+
+	c.held[lease.ShardID] = struct{}{}
+	return true, nil
+}
+
+// requestTransfer asks the owner of the lease to hand it to this worker; returns false if the lease
+// changed owner or another transfer was requested in the meantime
+func (c *LeaseCoordinator) requestTransfer(ctx context.Context, lease Lease) (bool, error) {
+	_, err := c.table.api.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(c.table.tableName),
+		Key:                 c.table.key(lease.ShardID),
+		UpdateExpression:    aws.String("SET #transfer = :me"),
+		ConditionExpression: aws.String("#owner = :owner AND attribute_not_exists(#transfer)"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner":    aws.String(ownerAttribute),
+			"#transfer": aws.String(transferAttribute),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":me":    {S: aws.String(c.workerID)},
+			":owner": {S: aws.String(lease.Owner)},
+		},
+	})
+	if err != nil {
+		if dynamodbstore.IsConditionalCheckFailed(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("unable to request transfer of lease for shard, %v - %v", lease.ShardID, err)
+	}
+	return true, nil
+}
+
+// updateOwned applies the update to the lease provided this worker still owns it and returns the
+// lease as updated
+func (c *LeaseCoordinator) updateOwned(ctx context.Context, shardID, updateExpr string, names map[string]*string, values map[string]*dynamodb.AttributeValue) (Lease, error) {
+	names["#owner"] = aws.String(ownerAttribute)
+	values[":me"] = &dynamodb.AttributeValue{S: aws.String(c.workerID)}
+
+	out, err := c.table.api.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(c.table.tableName),
+		Key:                       c.table.key(shardID),
+		UpdateExpression:          aws.String(updateExpr),
+		ConditionExpression:       aws.String("#owner = :me"),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		if dynamodbstore.IsConditionalCheckFailed(err) {
+			return Lease{}, errLeaseLost
+		}
+		return Lease{}, fmt.Errorf("unable to update lease for shard, %v - %v", shardID, err)
+	}
+	return leaseFromItem(out.Attributes), nil
+}
+
+// transferring returns true if another worker is waiting to take over the lease
+func (c *LeaseCoordinator) transferring(lease Lease) bool {
+	return lease.Transfer != "" && lease.Transfer != c.workerID
+}
+
+// Renew heartbeats every lease held by this worker.  Returns the ids of the shards whose leases were
+// lost to other workers and the ids of the shards other workers have asked to take over.  The caller
+// should stop reading the latter, checkpoint, and then Release them.  Neither are held any longer.
+func (c *LeaseCoordinator) Renew(ctx context.Context) (lost, transfers []string, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for shardID := range c.held {
+		lease, err := c.updateOwned(ctx, shardID, "SET #counter = #counter + :one",
+			map[string]*string{"#counter": aws.String(counterAttribute)},
+			map[string]*dynamodb.AttributeValue{":one": {N: aws.String("1")}},
+		)
+		if err == errLeaseLost {
+			delete(c.held, shardID)
+			lost = append(lost, shardID)
+			continue
+		}
+		if err != nil {
+			return lost, transfers, err
+		}
+		if c.transferring(lease) {
+			delete(c.held, shardID)
+			transfers = append(transfers, shardID)
+		}
+	}
+
+	sort.Strings(lost)
+	sort.Strings(transfers)
+	return lost, transfers, nil
+}
+
+// Rebalance ensures a lease exists for each of the shards and then claims this worker's fair share of
+// the unfinished leases, preferring free or expired leases and otherwise requesting the transfer of a
+// single lease from the most loaded worker.  Returns the ids of the shards currently leased by this
+// worker, excluding those being transferred to other workers.
+func (c *LeaseCoordinator) Rebalance(ctx context.Context, shardIDs []string) ([]string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	leases, err := c.Leases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]struct{}{}
+	for _, lease := range leases {
+		existing[lease.ShardID] = struct{}{}
+	}
+	for _, shardID := range shardIDs {
+		if _, ok := existing[shardID]; ok {
+			continue
+		}
+		if err := c.createLease(ctx, shardID); err != nil {
+			return nil, err
+		}
+		leases = append(leases, Lease{ShardID: shardID})
+	}
+
+	var (
+		now        = c.now()
+		unfinished = 0
+		available  []Lease
+		handing    = map[string]struct{}{}
+		byOwner    = map[string][]Lease{c.workerID: nil}
+	)
+	for _, lease := range leases {
+		if lease.Finished {
+			delete(c.held, lease.ShardID)
+			continue
+		}
+		unfinished++
+
+		if o, ok := c.observed[lease.ShardID]; !ok || o.counter != lease.Counter {
+			c.observed[lease.ShardID] = observation{counter: lease.Counter, at: now}
+		}
+
+		expired := now.Sub(c.observed[lease.ShardID].at) > c.leaseDuration
+
+		switch {
+		case lease.Owner == c.workerID && c.transferring(lease):
+			// held until Renew reports the transfer, but no longer read
+			c.held[lease.ShardID] = struct{}{}
+			handing[lease.ShardID] = struct{}{}
+			byOwner[lease.Transfer] = append(byOwner[lease.Transfer], lease)
+		case lease.Transfer != "" && !expired && (lease.Owner != "" || c.transferring(lease)):
+			// counts against the worker taking it over
+			byOwner[lease.Transfer] = append(byOwner[lease.Transfer], lease)
+		case lease.Owner == c.workerID:
+			c.held[lease.ShardID] = struct{}{}
+			byOwner[c.workerID] = append(byOwner[c.workerID], lease)
+		case lease.Owner == "" && lease.Transfer == c.workerID:
+			// released to this worker
+			available = append([]Lease{lease}, available...)
+		case lease.Owner == "" || expired:
+			available = append(available, lease)
+		default:
+			byOwner[lease.Owner] = append(byOwner[lease.Owner], lease)
+		}
+	}
+
+	var (
+		workers = len(byOwner)
+		target  = (unfinished + workers - 1) / workers
+		need    = target - len(byOwner[c.workerID])
+	)
+
+	for _, lease := range available {
+		if need <= 0 {
+			break
+		}
+		ok, err := c.takeLease(ctx, lease)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			need--
+		}
+	}
+
+	if need > 0 {
+		var victim string
+		for owner, owned := range byOwner {
+			if owner == c.workerID {
+				continue
+			}
+			if victim == "" || len(owned) > len(byOwner[victim]) || (len(owned) == len(byOwner[victim]) && owner < victim) {
+				victim = owner
+			}
+		}
+		if victim != "" && len(byOwner[victim]) > target {
+			for _, lease := range byOwner[victim] {
+				if lease.Owner != victim || lease.Transfer != "" {
+					continue
+				}
+				if _, err := c.requestTransfer(ctx, lease); err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+
+	held := make([]string, 0, len(c.held))
+	for shardID := range c.held {
+		if _, ok := handing[shardID]; !ok {
+			held = append(held, shardID)
+		}
+	}
+	sort.Strings(held)
+	return held, nil
+}
+
+// Release gives up this worker's lease on the shard so that another worker may take it immediately.
+// Completes the transfer of a lease reported by Renew.
+func (c *LeaseCoordinator) Release(ctx context.Context, shardID string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.release(ctx, shardID)
+}
+
+func (c *LeaseCoordinator) release(ctx context.Context, shardID string) error {
+	delete(c.held, shardID)
+
+	_, err := c.updateOwned(ctx, shardID, "REMOVE #owner", map[string]*string{}, map[string]*dynamodb.AttributeValue{})
+	if err == errLeaseLost {
+		return nil
+	}
+	return err
+}
+
+// ReleaseAll gives up every lease held by this worker
+func (c *LeaseCoordinator) ReleaseAll(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for shardID := range c.held {
+		if err := c.release(ctx, shardID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load implements Checkpointer
+func (c *LeaseCoordinator) Load(ctx context.Context, shardID string) (Checkpoint, error) {
+	return c.table.Load(ctx, shardID)
+}
+
+// Save implements Checkpointer; fails if the lease is no longer held by this worker
+func (c *LeaseCoordinator) Save(ctx context.Context, shardID, sequenceNumber string) error {
+	_, err := c.updateOwned(ctx, shardID, "SET #sequence = :sequence",
+		map[string]*string{"#sequence": aws.String(sequenceAttribute)},
+		map[string]*dynamodb.AttributeValue{":sequence": {S: aws.String(sequenceNumber)}},
+	)
+	return err
+}
+
+// Finish implements Checkpointer; marks the shard finished and frees the lease
+func (c *LeaseCoordinator) Finish(ctx context.Context, shardID string) error {
+	_, err := c.updateOwned(ctx, shardID, "SET #finished = :finished REMOVE #owner",
+		map[string]*string{"#finished": aws.String(finishedAttribute)},
+		map[string]*dynamodb.AttributeValue{":finished": {BOOL: aws.Bool(true)}},
+	)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	delete(c.held, shardID)
+	c.mutex.Unlock()
+	return nil
+}
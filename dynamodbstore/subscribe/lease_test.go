@@ -0,0 +1,275 @@
+package subscribe
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+
+func newTestCoordinator(api *fakeDynamoDB, workerID string, clock *fakeClock) *LeaseCoordinator {
+	c := NewLeaseCoordinator(api, "leases", "group", workerID, WithLeaseDuration(time.Minute))
+	c.now = clock.Now
+	return c
+}
+
+func TestLeaseCoordinator_Steal(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		api    = newFakeDynamoDB(GroupKey, ShardKey)
+		clock  = &fakeClock{now: time.Now()}
+		w1     = newTestCoordinator(api, "w1", clock)
+		w2     = newTestCoordinator(api, "w2", clock)
+		shards = []string{"a", "b", "c", "d"}
+	)
+
+	held, err := w1.Rebalance(ctx, shards)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := held, shards; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// w2 requests the transfer of one lease per rebalance until the leases are evenly spread, but
+	// holds none until w1 hands them over
+	for i := 0; i < 3; i++ {
+		if _, err := w2.Rebalance(ctx, shards); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+	held, err = w2.Rebalance(ctx, shards)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got := len(held); got != 0 {
+		t.Fatalf("got %v; want 0", got)
+	}
+
+	// w1 stops reading the shards being transferred but may still checkpoint them
+	held, err = w1.Rebalance(ctx, shards)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := held, []string{"c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	lost, transfers, err := w1.Renew(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if len(lost) != 0 {
+		t.Fatalf("got %v; want none lost", lost)
+	}
+	if got, want := transfers, []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for _, shardID := range transfers {
+		if err := w1.Save(ctx, shardID, "100"); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+		if err := w1.Release(ctx, shardID); err != nil {
+			t.Fatalf("got %v; want nil", err)
+		}
+	}
+
+	// w2 takes the released leases along with their checkpoints
+	held, err = w2.Rebalance(ctx, shards)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := held, transfers; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	cp, err := w2.Load(ctx, "a")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := cp.SequenceNumber, "100"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// w1 may no longer checkpoint the transferred shards
+	if got, want := w1.Save(ctx, "a", "200"), errLeaseLost; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if err := w2.Save(ctx, "a", "200"); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+}
+
+func TestLeaseCoordinator_AbandonedTransfer(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		api    = newFakeDynamoDB(GroupKey, ShardKey)
+		clock  = &fakeClock{now: time.Now()}
+		w1     = newTestCoordinator(api, "w1", clock)
+		w2     = newTestCoordinator(api, "w2", clock)
+		shards = []string{"a", "b"}
+	)
+
+	if _, err := w1.Rebalance(ctx, shards); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if _, err := w2.Rebalance(ctx, shards); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// w1 stops without handing over the lease; w2 takes it once expired
+	clock.now = clock.now.Add(2 * time.Minute)
+	held, err := w2.Rebalance(ctx, shards)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := held, shards; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	leases, err := w2.Leases(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	for _, lease := range leases {
+		if lease.Transfer != "" {
+			t.Fatalf("got %v; want no transfer", lease.Transfer)
+		}
+	}
+}
+
+func TestLeaseCoordinator_Expired(t *testing.T) {
+	var (
+		ctx    = context.Background()
+		api    = newFakeDynamoDB(GroupKey, ShardKey)
+		clock  = &fakeClock{now: time.Now()}
+		w1     = newTestCoordinator(api, "w1", clock)
+		w2     = newTestCoordinator(api, "w2", clock)
+		shards = []string{"a", "b"}
+	)
+
+	if _, err := w1.Rebalance(ctx, shards); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := w1.Save(ctx, "a", "42"); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	// w2 first observes the leases, then w1 stops heartbeating
+	if _, err := w2.Rebalance(ctx, shards); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	clock.now = clock.now.Add(2 * time.Minute)
+
+	held, err := w2.Rebalance(ctx, shards)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := held, shards; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// the checkpoint is handed off with the lease
+	cp, err := w2.Load(ctx, "a")
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := cp.SequenceNumber, "42"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestLeaseCoordinator_Finish(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		api   = newFakeDynamoDB(GroupKey, ShardKey)
+		clock = &fakeClock{now: time.Now()}
+		w1    = newTestCoordinator(api, "w1", clock)
+	)
+
+	if _, err := w1.Rebalance(ctx, []string{"a", "b"}); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if err := w1.Finish(ctx, "a"); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	held, err := w1.Rebalance(ctx, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := held, []string{"b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	if err := w1.ReleaseAll(ctx); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	leases, err := w1.Leases(ctx)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	for _, lease := range leases {
+		if lease.Owner != "" {
+			t.Fatalf("got %v; want blank owner", lease.Owner)
+		}
+	}
+}
+
+func TestSubscriber_RunWithLeases(t *testing.T) {
+	streams := &fakeStream{
+		shards: []*dynamodbstreams.Shard{
+			makeShard("a", ""),
+			makeShard("b", ""),
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"a": {makeRecord("1", "abc", 1)},
+			"b": {makeRecord("2", "def", 1)},
+		},
+	}
+	api := newFakeDynamoDB(GroupKey, ShardKey)
+	coordinator := NewLeaseCoordinator(api, "leases", "group", "w1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := map[string]int{}
+	s, err := New("arn", func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		received[aggregateID] += len(records)
+		return nil
+	},
+		WithDynamoDBStreams(streams),
+		WithLeaseCoordinator(coordinator),
+		WithDescribeInterval(time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	go func() {
+		for ctx.Err() == nil {
+			a, _ := coordinator.Load(ctx, "a")
+			b, _ := coordinator.Load(ctx, "b")
+			if a.Finished && b.Finished {
+				cancel()
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	if err := s.Run(ctx); err != context.Canceled {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+	if got, want := received, map[string]int{"abc": 1, "def": 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package subscribe
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+// Option represents a functional configuration of *Subscriber
+type Option func(*Subscriber)
+
+// WithRegion specifies the AWS Region to connect to
+func WithRegion(region string) Option {
+	return func(s *Subscriber) {
+		s.region = region
+	}
+}
+
+// WithDynamoDBStreams allows the caller to specify a pre-configured reference to DynamoDB Streams
+func WithDynamoDBStreams(api dynamodbstreamsiface.DynamoDBStreamsAPI) Option {
+	return func(s *Subscriber) {
+		s.api = api
+	}
+}
+
+// WithCheckpointer specifies where progress through the stream is recorded; by default checkpoints
+// are held in memory and the stream is read from the trim horizon on each start
+func WithCheckpointer(checkpointer Checkpointer) Option {
+	return func(s *Subscriber) {
+		s.checkpointer = checkpointer
+	}
+}
+
+// WithLeaseCoordinator allows several workers within a consumer group to share the shards of the
+// stream.  The coordinator also acts as the Checkpointer.
+func WithLeaseCoordinator(c *LeaseCoordinator) Option {
+	return func(s *Subscriber) {
+		s.leases = c
+		s.checkpointer = c
+	}
+}
+
+// WithBatchSize specifies the maximum number of stream records read per request; defaults to 1000
+func WithBatchSize(batchSize int64) Option {
+	return func(s *Subscriber) {
+		s.batchSize = batchSize
+	}
+}
+
+// WithPollInterval specifies how long to wait before polling a shard that returned no records;
+// defaults to 1s
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *Subscriber) {
+		s.pollInterval = interval
+	}
+}
+
+// WithDescribeInterval specifies how often the stream is described to discover new shards;
+// defaults to 10s
+func WithDescribeInterval(interval time.Duration) Option {
+	return func(s *Subscriber) {
+		s.describeInterval = interval
+	}
+}
+
+// WithStartingPosition specifies where to begin reading shards that have no checkpoint; one of
+// dynamodbstreams.ShardIteratorTypeTrimHorizon (the default) or dynamodbstreams.ShardIteratorTypeLatest
+func WithStartingPosition(iteratorType string) Option {
+	return func(s *Subscriber) {
+		s.startingPosition = iteratorType
+	}
+}
+
+// WithUpcasters rewrites records read from the stream into their current schema before they are
+// passed to the handler; use the same upcasters as the store, see dynamodbstore.WithUpcasters
+func WithUpcasters(upcasters *dynamodbstore.Upcasters) Option {
+	return func(s *Subscriber) {
+		s.upcasters = upcasters
+	}
+}
+
+// WithIntegrityAlerter specifies where stream records that modify or remove committed events are
+// reported; by default they are logged.  The records are otherwise skipped, along with their
+// checkpoint, unless the alerter returns an error.
+func WithIntegrityAlerter(alert dynamodbstore.IntegrityAlerter) Option {
+	return func(s *Subscriber) {
+		s.alert = alert
+	}
+}
@@ -0,0 +1,436 @@
+// Package subscribe provides a long running consumer of the dynamodb stream of an eventsource table
+// for use outside of lambda e.g. ECS or against amazon/dynamodb-local.
+package subscribe
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+// Handler receives the records saved to an aggregate in the order they were saved.  Returning an
+// error stops the subscription without checkpointing the records.
+type Handler func(ctx context.Context, aggregateID string, records []eventsource.Record) error
+
+// Subscriber reads the dynamodb stream of an eventsource table.  Shards are read concurrently,
+// but a child shard is only read once its parent has been read to completion so the records of each
+// aggregate are delivered in order.
+type Subscriber struct {
+	streamARN        string
+	handler          Handler
+	region           string
+	api              dynamodbstreamsiface.DynamoDBStreamsAPI
+	checkpointer     Checkpointer
+	leases           *LeaseCoordinator
+	batchSize        int64
+	pollInterval     time.Duration
+	describeInterval time.Duration
+	startingPosition string
+	upcasters        *dynamodbstore.Upcasters
+	alert            dynamodbstore.IntegrityAlerter
+}
+
+// New constructs a Subscriber that delivers the events in the stream to the handler
+func New(streamARN string, handler Handler, opts ...Option) (*Subscriber, error) {
+	s := &Subscriber{
+		streamARN:        streamARN,
+		handler:          handler,
+		region:           dynamodbstore.DefaultRegion,
+		batchSize:        1000,
+		pollInterval:     time.Second,
+		describeInterval: 10 * time.Second,
+		startingPosition: dynamodbstreams.ShardIteratorTypeTrimHorizon,
+		alert:            dynamodbstore.LogIntegrityViolation,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.checkpointer == nil {
+		s.checkpointer = newMemoryCheckpointer()
+	}
+
+	if s.api == nil {
+		cfg := &aws.Config{Region: aws.String(s.region)}
+		sess, err := session.NewSession(cfg)
+		if err != nil {
+			if v, ok := err.(awserr.Error); ok {
+				return nil, eventsource.NewError(err, "Unable to create AWS Session - %v [%v]", v.Message(), v.Code())
+			}
+			return nil, err
+		}
+		s.api = dynamodbstreams.New(sess)
+	}
+
+	return s, nil
+}
+
+// Run reads the stream until the context is cancelled or an error occurs
+func (s *Subscriber) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		running  = map[string]context.CancelFunc{}
+		handoff  = map[string]struct{}{}
+		finished = map[string]struct{}{}
+		errs     = make(chan error, 1)
+	)
+
+	defer func() {
+		cancel()
+		wg.Wait()
+
+		if s.leases != nil {
+			if err := s.leases.ReleaseAll(context.Background()); err != nil {
+				log.Printf("unable to release leases - %v\n", err)
+			}
+		}
+	}()
+
+	for {
+		shards, err := s.describeShards(ctx)
+		if err != nil {
+			return err
+		}
+
+		if s.leases != nil {
+			shards, err = s.leasedShards(ctx, shards, func(shardID string, transfer bool) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				fn, ok := running[shardID]
+				if !ok {
+					if transfer {
+						return s.leases.Release(ctx, shardID)
+					}
+					return nil
+				}
+
+				// the lease is released once the shard has stopped and checkpointed
+				if transfer {
+					handoff[shardID] = struct{}{}
+				}
+				fn()
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		mutex.Lock()
+		ready, err := s.readyShards(ctx, shards, running, finished)
+		mutex.Unlock()
+		if err != nil {
+			return err
+		}
+
+		for _, shard := range ready {
+			shardID := *shard.ShardId
+			shardCtx, shardCancel := context.WithCancel(ctx)
+
+			mutex.Lock()
+			running[shardID] = shardCancel
+			mutex.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer shardCancel()
+
+				err := s.readShard(shardCtx, shardID)
+
+				mutex.Lock()
+				delete(running, shardID)
+				if err == nil {
+					finished[shardID] = struct{}{}
+				}
+				_, transfer := handoff[shardID]
+				delete(handoff, shardID)
+				mutex.Unlock()
+
+				if transfer {
+					if err := s.leases.Release(context.Background(), shardID); err != nil {
+						log.Printf("unable to release lease for shard, %v - %v\n", shardID, err)
+					}
+				}
+
+				// losing a lease or having the shard stopped is not fatal to the subscriber
+				if err != nil && err != errLeaseLost && shardCtx.Err() == nil {
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			return err
+		case <-time.After(s.describeInterval):
+		}
+	}
+}
+
+// leasedShards renews and rebalances the leases of this worker and returns the shards it holds.
+// stop is called for each shard whose lease was lost or is to be transferred to another worker; a
+// transferred lease must be released once the shard has stopped.
+func (s *Subscriber) leasedShards(ctx context.Context, shards []*dynamodbstreams.Shard, stop func(shardID string, transfer bool) error) ([]*dynamodbstreams.Shard, error) {
+	lost, transfers, err := s.leases.Renew(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, shardID := range lost {
+		log.Printf("lease for shard, %v, was taken by another worker\n", shardID)
+		if err := stop(shardID, false); err != nil {
+			return nil, err
+		}
+	}
+	for _, shardID := range transfers {
+		log.Printf("handing lease for shard, %v, to another worker\n", shardID)
+		if err := stop(shardID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	shardIDs := make([]string, 0, len(shards))
+	for _, shard := range shards {
+		shardIDs = append(shardIDs, *shard.ShardId)
+	}
+
+	held, err := s.leases.Rebalance(ctx, shardIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	owned := map[string]struct{}{}
+	for _, shardID := range held {
+		owned[shardID] = struct{}{}
+	}
+
+	var leased []*dynamodbstreams.Shard
+	for _, shard := range shards {
+		if _, ok := owned[*shard.ShardId]; ok {
+			leased = append(leased, shard)
+		}
+	}
+	return leased, nil
+}
+
+// describeShards returns every shard currently in the stream
+func (s *Subscriber) describeShards(ctx context.Context) ([]*dynamodbstreams.Shard, error) {
+	var (
+		shards []*dynamodbstreams.Shard
+		input  = &dynamodbstreams.DescribeStreamInput{StreamArn: aws.String(s.streamARN)}
+	)
+
+	for {
+		out, err := s.api.DescribeStreamWithContext(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to describe stream, %v - %v", s.streamARN, err)
+		}
+
+		shards = append(shards, out.StreamDescription.Shards...)
+
+		if out.StreamDescription.LastEvaluatedShardId == nil {
+			return shards, nil
+		}
+		input.ExclusiveStartShardId = out.StreamDescription.LastEvaluatedShardId
+	}
+}
+
+// readyShards returns the shards that are neither running nor finished and whose parent, if still
+// present in the stream, has been finished
+func (s *Subscriber) readyShards(ctx context.Context, shards []*dynamodbstreams.Shard, running map[string]context.CancelFunc, finished map[string]struct{}) ([]*dynamodbstreams.Shard, error) {
+	present := map[string]struct{}{}
+	for _, shard := range shards {
+		present[*shard.ShardId] = struct{}{}
+	}
+
+	isFinished := func(shardID string) (bool, error) {
+		if _, ok := finished[shardID]; ok {
+			return true, nil
+		}
+		cp, err := s.checkpointer.Load(ctx, shardID)
+		if err != nil {
+			return false, err
+		}
+		if cp.Finished {
+			finished[shardID] = struct{}{}
+		}
+		return cp.Finished, nil
+	}
+
+	var ready []*dynamodbstreams.Shard
+	for _, shard := range shards {
+		shardID := *shard.ShardId
+		if _, ok := running[shardID]; ok {
+			continue
+		}
+
+		done, err := isFinished(shardID)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			continue
+		}
+
+		if parentID := shard.ParentShardId; parentID != nil {
+			if _, ok := present[*parentID]; ok {
+				done, err := isFinished(*parentID)
+				if err != nil {
+					return nil, err
+				}
+				if !done {
+					continue
+				}
+			}
+		}
+
+		ready = append(ready, shard)
+	}
+
+	return ready, nil
+}
+
+// shardIterator returns an iterator positioned after the sequence number or at the starting position
+// if there is no sequence number
+func (s *Subscriber) shardIterator(ctx context.Context, shardID, sequenceNumber string) (*string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(s.streamARN),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(s.startingPosition),
+	}
+	if sequenceNumber != "" {
+		input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeAfterSequenceNumber)
+		input.SequenceNumber = aws.String(sequenceNumber)
+	}
+
+	out, err := s.api.GetShardIteratorWithContext(ctx, input)
+	if err != nil {
+		if v, ok := err.(awserr.Error); ok && v.Code() == dynamodbstreams.ErrCodeTrimmedDataAccessException && sequenceNumber != "" {
+			log.Printf("checkpoint for shard, %v, has been trimmed; reading from trim horizon\n", shardID)
+			input.ShardIteratorType = aws.String(dynamodbstreams.ShardIteratorTypeTrimHorizon)
+			input.SequenceNumber = nil
+			out, err = s.api.GetShardIteratorWithContext(ctx, input)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to get iterator for shard, %v - %v", shardID, err)
+		}
+	}
+
+	return out.ShardIterator, nil
+}
+
+// readShard delivers the records in the shard to the handler until the shard is closed
+func (s *Subscriber) readShard(ctx context.Context, shardID string) error {
+	cp, err := s.checkpointer.Load(ctx, shardID)
+	if err != nil {
+		return err
+	}
+	last := cp.SequenceNumber
+
+	// checkpoint whatever was handled before the shard is handed off or the subscriber stops
+	checkpointed := last
+	defer func() {
+		if last != checkpointed {
+			if err := s.checkpointer.Save(context.Background(), shardID, last); err != nil && err != errLeaseLost {
+				log.Printf("unable to checkpoint shard, %v - %v\n", shardID, err)
+			}
+		}
+	}()
+
+	iterator, err := s.shardIterator(ctx, shardID, last)
+	if err != nil {
+		return err
+	}
+
+	for iterator != nil {
+		out, err := s.api.GetRecordsWithContext(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: iterator,
+			Limit:         aws.Int64(s.batchSize),
+		})
+		if err != nil {
+			if v, ok := err.(awserr.Error); ok && v.Code() == dynamodbstreams.ErrCodeExpiredIteratorException {
+				if iterator, err = s.shardIterator(ctx, shardID, last); err != nil {
+					return err
+				}
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("unable to get records for shard, %v - %v", shardID, err)
+		}
+
+		for _, record := range out.Records {
+			if err := s.handleRecord(ctx, record); err != nil {
+				return err
+			}
+			if record.Dynamodb != nil && record.Dynamodb.SequenceNumber != nil {
+				last = *record.Dynamodb.SequenceNumber
+			}
+		}
+
+		if len(out.Records) > 0 {
+			if err := s.checkpointer.Save(ctx, shardID, last); err != nil {
+				return err
+			}
+			checkpointed = last
+		}
+
+		iterator = out.NextShardIterator
+		if iterator != nil && len(out.Records) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.pollInterval):
+			}
+		}
+	}
+
+	return s.checkpointer.Finish(ctx, shardID)
+}
+
+// handleRecord delivers the events contained in the stream record, if any, to the handler.  Integrity
+// violations are permanent so are passed to the alerter rather than retried.
+func (s *Subscriber) handleRecord(ctx context.Context, record *dynamodbstreams.Record) error {
+	if record.Dynamodb == nil || record.Dynamodb.Keys == nil {
+		return nil
+	}
+
+	changeSet, err := dynamodbstore.ParseStreamRecord(record)
+	if v, ok := err.(*dynamodbstore.IntegrityError); ok {
+		err = s.alert(ctx, v)
+	}
+	if err != nil {
+		return err
+	}
+	if len(changeSet.Records) == 0 {
+		return nil
+	}
+
+	records, err := s.upcasters.Upcast(changeSet.Records...)
+	if err != nil {
+		return err
+	}
+
+	return s.handler(ctx, changeSet.AggregateID, records)
+}
@@ -0,0 +1,278 @@
+package subscribe
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+	"github.com/eventsource-ecosystem/eventsource"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+// fakeStream is an in-process dynamodb stream; closed shards return nil iterators once read
+type fakeStream struct {
+	dynamodbstreamsiface.DynamoDBStreamsAPI
+	shards  []*dynamodbstreams.Shard
+	records map[string][]*dynamodbstreams.Record
+}
+
+func (f *fakeStream) DescribeStreamWithContext(_ aws.Context, _ *dynamodbstreams.DescribeStreamInput, _ ...request.Option) (*dynamodbstreams.DescribeStreamOutput, error) {
+	return &dynamodbstreams.DescribeStreamOutput{
+		StreamDescription: &dynamodbstreams.StreamDescription{Shards: f.shards},
+	}, nil
+}
+
+func (f *fakeStream) GetShardIteratorWithContext(_ aws.Context, input *dynamodbstreams.GetShardIteratorInput, _ ...request.Option) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	offset := 0
+	if *input.ShardIteratorType == dynamodbstreams.ShardIteratorTypeAfterSequenceNumber {
+		for i, record := range f.records[*input.ShardId] {
+			if *record.Dynamodb.SequenceNumber == *input.SequenceNumber {
+				offset = i + 1
+			}
+		}
+	}
+	return &dynamodbstreams.GetShardIteratorOutput{
+		ShardIterator: aws.String(*input.ShardId + "|" + strconv.Itoa(offset)),
+	}, nil
+}
+
+func (f *fakeStream) GetRecordsWithContext(_ aws.Context, input *dynamodbstreams.GetRecordsInput, _ ...request.Option) (*dynamodbstreams.GetRecordsOutput, error) {
+	segments := strings.Split(*input.ShardIterator, "|")
+	shardID := segments[0]
+	offset, _ := strconv.Atoi(segments[1])
+
+	records := f.records[shardID][offset:]
+	if n := int(*input.Limit); len(records) > n {
+		records = records[:n]
+	}
+
+	out := &dynamodbstreams.GetRecordsOutput{Records: records}
+	if next := offset + len(records); next < len(f.records[shardID]) {
+		out.NextShardIterator = aws.String(shardID + "|" + strconv.Itoa(next))
+	}
+	return out, nil
+}
+
+func makeShard(shardID, parentID string) *dynamodbstreams.Shard {
+	shard := &dynamodbstreams.Shard{ShardId: aws.String(shardID)}
+	if parentID != "" {
+		shard.ParentShardId = aws.String(parentID)
+	}
+	return shard
+}
+
+func makeRecord(sequenceNumber, aggregateID string, version int) *dynamodbstreams.Record {
+	return &dynamodbstreams.Record{
+		EventName: aws.String(dynamodbstreams.OperationTypeModify),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			SequenceNumber: aws.String(sequenceNumber),
+			Keys: map[string]*dynamodb.AttributeValue{
+				dynamodbstore.HashKey:  {S: aws.String(aggregateID)},
+				dynamodbstore.RangeKey: {N: aws.String("0")},
+			},
+			NewImage: map[string]*dynamodb.AttributeValue{
+				dynamodbstore.HashKey:       {S: aws.String(aggregateID)},
+				"_" + strconv.Itoa(version): {B: []byte(fmt.Sprintf("%v-%v", aggregateID, version))},
+			},
+		},
+	}
+}
+
+func TestSubscriber_Run(t *testing.T) {
+	// child-b is listed ahead of its parent to verify ordering does not depend on the listing
+	api := &fakeStream{
+		shards: []*dynamodbstreams.Shard{
+			makeShard("child-b", "parent"),
+			makeShard("parent", ""),
+			makeShard("child-a", "parent"),
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"parent": {
+				makeRecord("1", "abc", 1),
+				makeRecord("2", "abc", 2),
+				{Dynamodb: &dynamodbstreams.StreamRecord{SequenceNumber: aws.String("3")}},
+			},
+			"child-a": {
+				makeRecord("4", "abc", 3),
+			},
+			"child-b": {
+				makeRecord("5", "def", 1),
+			},
+		},
+	}
+
+	var (
+		mutex       sync.Mutex
+		received    = map[string][]int{}
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	)
+	defer cancel()
+
+	checkpointer := newMemoryCheckpointer()
+	s, err := New("arn", func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		for _, record := range records {
+			received[aggregateID] = append(received[aggregateID], record.Version)
+		}
+		if len(received["abc"]) == 3 && len(received["def"]) == 1 {
+			cancel()
+		}
+		return nil
+	},
+		WithDynamoDBStreams(api),
+		WithCheckpointer(checkpointer),
+		WithBatchSize(1),
+		WithPollInterval(time.Millisecond),
+		WithDescribeInterval(time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := s.Run(ctx); err != context.Canceled {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+
+	if got, want := received["abc"], []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	cp, _ := checkpointer.Load(context.Background(), "parent")
+	if got, want := cp, (Checkpoint{SequenceNumber: "3", Finished: true}); got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSubscriber_IntegrityViolation(t *testing.T) {
+	// alters a committed event while appending another
+	tampered := makeRecord("2", "abc", 2)
+	tampered.Dynamodb.OldImage = map[string]*dynamodb.AttributeValue{
+		"_1": {B: []byte("original")},
+	}
+	tampered.Dynamodb.NewImage["_1"] = &dynamodb.AttributeValue{B: []byte("altered")}
+
+	api := &fakeStream{
+		shards: []*dynamodbstreams.Shard{
+			makeShard("shard", ""),
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"shard": {
+				makeRecord("1", "abc", 1),
+				tampered,
+				makeRecord("3", "abc", 3),
+			},
+		},
+	}
+
+	var (
+		received    []int
+		violations  []string
+		ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	)
+	defer cancel()
+
+	checkpointer := newMemoryCheckpointer()
+	s, err := New("arn", func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		for _, record := range records {
+			received = append(received, record.Version)
+		}
+		if len(received) == 3 {
+			cancel()
+		}
+		return nil
+	},
+		WithDynamoDBStreams(api),
+		WithCheckpointer(checkpointer),
+		WithPollInterval(time.Millisecond),
+		WithDescribeInterval(time.Millisecond),
+		WithIntegrityAlerter(func(ctx context.Context, violation *dynamodbstore.IntegrityError) error {
+			violations = append(violations, violation.SequenceNumber)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	if err := s.Run(ctx); err != context.Canceled {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+
+	if got, want := received, []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := violations, []string{"2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestSubscriber_ResumeFromCheckpoint(t *testing.T) {
+	api := &fakeStream{
+		shards: []*dynamodbstreams.Shard{
+			makeShard("shard", ""),
+		},
+		records: map[string][]*dynamodbstreams.Record{
+			"shard": {
+				makeRecord("1", "abc", 1),
+				makeRecord("2", "abc", 2),
+			},
+		},
+	}
+
+	checkpointer := newMemoryCheckpointer()
+	checkpointer.Save(context.Background(), "shard", "1")
+
+	var versions []int
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, err := New("arn", func(ctx context.Context, aggregateID string, records []eventsource.Record) error {
+		for _, record := range records {
+			versions = append(versions, record.Version)
+		}
+		return nil
+	},
+		WithDynamoDBStreams(api),
+		WithCheckpointer(checkpointer),
+		WithDescribeInterval(time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	go func() {
+		for {
+			if cp, _ := checkpointer.Load(ctx, "shard"); cp.Finished {
+				cancel()
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	if err := s.Run(ctx); err != context.Canceled {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+	if got, want := versions, []int{2}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestMakeCreateCheckpointTableInput(t *testing.T) {
+	input := MakeCreateCheckpointTableInput("checkpoints")
+	if err := input.Validate(); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+}
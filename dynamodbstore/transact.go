@@ -0,0 +1,59 @@
+package dynamodbstore
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// makeUpdate converts an UpdateItemInput into an Update suitable for use within a transaction
+func makeUpdate(input *dynamodb.UpdateItemInput) *dynamodb.Update {
+	return &dynamodb.Update{
+		TableName:                 input.TableName,
+		Key:                       input.Key,
+		ConditionExpression:       input.ConditionExpression,
+		UpdateExpression:          input.UpdateExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}
+}
+
+// dump writes v to the debug writer when debugging is enabled
+func (s *Store) dump(v interface{}) {
+	if !s.debug {
+		return
+	}
+
+	encoder := json.NewEncoder(s.writer)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
+
+// writeItems applies the items as a single atomic write.  A lone update is sent as an UpdateItem
+// as it costs half as much as the equivalent transaction.
+func (s *Store) writeItems(ctx context.Context, items ...*dynamodb.TransactWriteItem) error {
+	if len(items) == 1 && items[0].Update != nil {
+		update := items[0].Update
+		input := &dynamodb.UpdateItemInput{
+			TableName:                 update.TableName,
+			Key:                       update.Key,
+			ConditionExpression:       update.ConditionExpression,
+			UpdateExpression:          update.UpdateExpression,
+			ExpressionAttributeNames:  update.ExpressionAttributeNames,
+			ExpressionAttributeValues: update.ExpressionAttributeValues,
+		}
+		s.dump(input)
+
+		_, err := s.api.UpdateItemWithContext(ctx, input)
+		return err
+	}
+
+	input := &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	}
+	s.dump(input)
+
+	_, err := s.api.TransactWriteItemsWithContext(ctx, input)
+	return err
+}
@@ -0,0 +1,120 @@
+package dynamodbstore
+
+import (
+	"encoding/json"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// UpcastFunc rewrites the data of an event from one schema version to the next
+type UpcastFunc func(data []byte) ([]byte, error)
+
+// SchemaReader returns the event type and schema version of the serialized event
+type SchemaReader func(data []byte) (eventType string, schemaVersion int, err error)
+
+// Upcaster holds the chain of functions that successively rewrite events of EventType starting at
+// SchemaVersion; Chain[i] rewrites schema version SchemaVersion+i to SchemaVersion+i+1
+type Upcaster struct {
+	EventType     string
+	SchemaVersion int
+	Chain         []UpcastFunc
+}
+
+type upcastKey struct {
+	eventType     string
+	schemaVersion int
+}
+
+// Upcasters rewrites events saved with historic schemas into the current schema
+type Upcasters struct {
+	reader SchemaReader
+	chains map[upcastKey][]UpcastFunc
+}
+
+// NewUpcasters constructs an Upcasters that uses reader to identify the schema of each event.  The
+// chains of upcasters for an event type may be split across several Upcaster values provided each
+// starts where another leaves off.
+func NewUpcasters(reader SchemaReader, upcasters ...Upcaster) *Upcasters {
+	u := &Upcasters{
+		reader: reader,
+		chains: map[upcastKey][]UpcastFunc{},
+	}
+
+	for _, upcaster := range upcasters {
+		if len(upcaster.Chain) == 0 {
+			continue
+		}
+		key := upcastKey{eventType: upcaster.EventType, schemaVersion: upcaster.SchemaVersion}
+		u.chains[key] = upcaster.Chain
+	}
+
+	return u
+}
+
+// Upcast returns the records rewritten into the current schema.  Records keep their versions and the
+// records passed in are not modified, so Upcast may be used with records returned by Changes.
+func (u *Upcasters) Upcast(records ...eventsource.Record) ([]eventsource.Record, error) {
+	if u == nil || len(u.chains) == 0 {
+		return records, nil
+	}
+
+	upcasted := make([]eventsource.Record, 0, len(records))
+	for _, record := range records {
+		eventType, schemaVersion, err := u.reader(record.Data)
+		if err != nil {
+			return nil, eventsource.NewError(err, ErrUpcast, "unable to read schema of event, %v", record.Version)
+		}
+
+		data := record.Data
+		for {
+			chain, ok := u.chains[upcastKey{eventType: eventType, schemaVersion: schemaVersion}]
+			if !ok {
+				break
+			}
+
+			for _, fn := range chain {
+				if data, err = fn(data); err != nil {
+					return nil, eventsource.NewError(err, ErrUpcast, "unable to upcast event, %v, of type %v from schema version %v", record.Version, eventType, schemaVersion)
+				}
+				schemaVersion++
+			}
+		}
+
+		upcasted = append(upcasted, eventsource.Record{
+			Version: record.Version,
+			Data:    data,
+		})
+	}
+
+	return upcasted, nil
+}
+
+// JSONSchemaReader returns a SchemaReader for events written by eventsource.JSONSerializer or by a
+// Registry using json codecs.  The event type is read from the serializer envelope and the schema
+// version from the named field of the event itself; events without the field are treated as schema
+// version 0.
+func JSONSchemaReader(field string) SchemaReader {
+	return func(data []byte) (string, int, error) {
+		var envelope struct {
+			Type string          `json:"t"`
+			Data json.RawMessage `json:"d"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return "", 0, err
+		}
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(envelope.Data, &fields); err != nil {
+			return "", 0, err
+		}
+
+		schemaVersion := 0
+		if raw, ok := fields[field]; ok {
+			if err := json.Unmarshal(raw, &schemaVersion); err != nil {
+				return "", 0, err
+			}
+		}
+
+		return envelope.Type, schemaVersion, nil
+	}
+}
@@ -0,0 +1,131 @@
+package dynamodbstore
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/eventsource-ecosystem/eventsource"
+)
+
+// testSchemaReader reads events of the form {type}:{schemaVersion}:{payload}
+func testSchemaReader(data []byte) (string, int, error) {
+	parts := bytes.SplitN(data, []byte(":"), 3)
+	if len(parts) != 3 {
+		return "", 0, errors.New("bad event")
+	}
+	return string(parts[0]), int(parts[1][0] - '0'), nil
+}
+
+func testUpcast(to byte) UpcastFunc {
+	return func(data []byte) ([]byte, error) {
+		parts := bytes.SplitN(data, []byte(":"), 3)
+		return []byte(string(parts[0]) + ":" + string(to) + ":" + string(parts[2]) + "+"), nil
+	}
+}
+
+func TestUpcasters_Upcast(t *testing.T) {
+	upcasters := NewUpcasters(testSchemaReader,
+		Upcaster{EventType: "created", SchemaVersion: 1, Chain: []UpcastFunc{testUpcast('2'), testUpcast('3')}},
+		Upcaster{EventType: "created", SchemaVersion: 3, Chain: []UpcastFunc{testUpcast('4')}},
+	)
+
+	records := []eventsource.Record{
+		{Version: 1, Data: []byte("created:1:a")},
+		{Version: 2, Data: []byte("created:3:b")},
+		{Version: 3, Data: []byte("created:4:c")},
+		{Version: 4, Data: []byte("deleted:1:d")},
+	}
+	original := []eventsource.Record{
+		{Version: 1, Data: []byte("created:1:a")},
+		{Version: 2, Data: []byte("created:3:b")},
+		{Version: 3, Data: []byte("created:4:c")},
+		{Version: 4, Data: []byte("deleted:1:d")},
+	}
+
+	got, err := upcasters.Upcast(records...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+
+	want := []eventsource.Record{
+		{Version: 1, Data: []byte("created:4:a+++")},
+		{Version: 2, Data: []byte("created:4:b+")},
+		{Version: 3, Data: []byte("created:4:c")},
+		{Version: 4, Data: []byte("deleted:1:d")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if !reflect.DeepEqual(records, original) {
+		t.Fatalf("records were modified; got %v", records)
+	}
+}
+
+func TestUpcasters_UpcastError(t *testing.T) {
+	boom := errors.New("boom")
+	upcasters := NewUpcasters(testSchemaReader,
+		Upcaster{EventType: "created", SchemaVersion: 1, Chain: []UpcastFunc{
+			func(data []byte) ([]byte, error) { return nil, boom },
+		}},
+	)
+
+	for _, data := range []string{"created:1:a", "bogus"} {
+		_, err := upcasters.Upcast(eventsource.Record{Version: 1, Data: []byte(data)})
+		if !eventsource.ErrHasCode(err, ErrUpcast) {
+			t.Fatalf("got %v; want %v", err, ErrUpcast)
+		}
+	}
+}
+
+func TestUpcasters_Nil(t *testing.T) {
+	var upcasters *Upcasters
+
+	records := []eventsource.Record{{Version: 1, Data: []byte("a")}}
+	got, err := upcasters.Upcast(records...)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if !reflect.DeepEqual(got, records) {
+		t.Fatalf("got %v; want %v", got, records)
+	}
+}
+
+func TestJSONSchemaReader(t *testing.T) {
+	type Created struct {
+		eventsource.Model
+		Schema int `json:"schema,omitempty"`
+	}
+
+	serializer := eventsource.NewJSONSerializer(Created{})
+	reader := JSONSchemaReader("schema")
+
+	testCases := map[string]struct {
+		Event  Created
+		Schema int
+	}{
+		"absent":  {Event: Created{}, Schema: 0},
+		"present": {Event: Created{Schema: 2}, Schema: 2},
+	}
+
+	for label, tc := range testCases {
+		t.Run(label, func(t *testing.T) {
+			record, err := serializer.MarshalEvent(tc.Event)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+
+			eventType, schemaVersion, err := reader(record.Data)
+			if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+			if got, want := eventType, "Created"; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if got, want := schemaVersion, tc.Schema; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+// Alerter is notified of stream records that modify or remove committed events
+type Alerter func(ctx context.Context, tableArn string, violation *dynamodbstore.IntegrityError) error
+
+// alertMessage is the json message published for each integrity violation
+type alertMessage struct {
+	TableArn       string `json:"tableArn"`
+	Code           string `json:"code"`
+	Message        string `json:"message"`
+	AggregateID    string `json:"aggregateID"`
+	Partition      int    `json:"partition"`
+	SequenceNumber string `json:"sequenceNumber"`
+	Modified       []int  `json:"modified,omitempty"`
+	Removed        []int  `json:"removed,omitempty"`
+}
+
+func makeAlertMessage(tableArn string, violation *dynamodbstore.IntegrityError) alertMessage {
+	return alertMessage{
+		TableArn:       tableArn,
+		Code:           violation.Code(),
+		Message:        violation.Message(),
+		AggregateID:    violation.AggregateID,
+		Partition:      violation.Partition,
+		SequenceNumber: violation.SequenceNumber,
+		Modified:       violation.Modified,
+		Removed:        violation.Removed,
+	}
+}
+
+// LogAlerter writes integrity violations to the log; used when no alert topic is configured
+func LogAlerter(ctx context.Context, tableArn string, violation *dynamodbstore.IntegrityError) error {
+	log.Printf("integrity violation on table, %v - %v\n", tableArn, violation)
+	return nil
+}
+
+// NewSNSAlerter publishes integrity violations, as json, to the specified topic
+func NewSNSAlerter(api snsiface.SNSAPI, topicArn string) Alerter {
+	return func(ctx context.Context, tableArn string, violation *dynamodbstore.IntegrityError) error {
+		data, err := json.Marshal(makeAlertMessage(tableArn, violation))
+		if err != nil {
+			return fmt.Errorf("unable to marshal alert - %v", err)
+		}
+
+		input := sns.PublishInput{
+			Subject:  aws.String(dynamodbstore.ErrIntegrity),
+			Message:  aws.String(string(data)),
+			TopicArn: aws.String(topicArn),
+		}
+		if _, err := api.PublishWithContext(ctx, &input); err != nil {
+			return fmt.Errorf("unable to publish alert to topic, %v - %v", topicArn, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
+)
+
+type publishAPI struct {
+	snsiface.SNSAPI
+	inputs []*sns.PublishInput
+}
+
+func (p *publishAPI) PublishWithContext(_ aws.Context, input *sns.PublishInput, _ ...request.Option) (*sns.PublishOutput, error) {
+	p.inputs = append(p.inputs, input)
+	return &sns.PublishOutput{}, nil
+}
+
+func TestSNSAlerter(t *testing.T) {
+	api := &publishAPI{}
+	violation := &dynamodbstore.IntegrityError{
+		AggregateID:    "abc",
+		Partition:      0,
+		SequenceNumber: "123",
+		Modified:       []int{42},
+	}
+
+	err := NewSNSAlerter(api, "topic")(context.Background(), "table-arn", violation)
+	if err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if got, want := len(api.inputs), 1; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := *api.inputs[0].TopicArn, "topic"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	var got alertMessage
+	if err := json.Unmarshal([]byte(*api.inputs[0].Message), &got); err != nil {
+		t.Fatalf("got %v; want nil", err)
+	}
+	if want := makeAlertMessage("table-arn", violation); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v; want %#v", got, want)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/firehose/firehoseiface"
 	"github.com/eventsource-ecosystem/eventsource"
 	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/awstag"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
 )
 
 func NewFirehoseFactory(api firehoseiface.FirehoseAPI, roleARN, keyARN string) ProducerFactory {
@@ -30,14 +31,14 @@ func NewFirehoseFactory(api firehoseiface.FirehoseAPI, roleARN, keyARN string) P
 			return nil, fmt.Errorf("invalid firehose delivery stream tag: expected {streamName}:{bucket}, got %v", value)
 		}
 
-		var (
-			streamName, bucket = values[0], values[1]
-			segments           = strings.Split(tableArn, "/")
-			tableName          = segments[len(segments)-1]
-		)
+		arn, err := dynamodbstore.ParseARN(tableArn)
+		if err != nil {
+			return nil, err
+		}
 
+		streamName, bucket := values[0], values[1]
 		return []Producer{
-			makeFirehoseProducer(api, tableName, streamName, bucket, roleARN, keyARN),
+			makeFirehoseProducer(api, arn, streamName, bucket, roleARN, keyARN),
 		}, nil
 	}
 }
@@ -68,7 +69,7 @@ func waitForStreamActive(ctx context.Context, api firehoseiface.FirehoseAPI, str
 	}
 }
 
-func createStreamIfNotExists(ctx context.Context, api firehoseiface.FirehoseAPI, tableName, streamName, bucket, roleARN, keyARN string) error {
+func createStreamIfNotExists(ctx context.Context, api firehoseiface.FirehoseAPI, arn dynamodbstore.ARN, streamName, bucket, roleARN, keyARN string) error {
 	describeInput := firehose.DescribeDeliveryStreamInput{
 		DeliveryStreamName: aws.String(streamName),
 	}
@@ -77,12 +78,10 @@ func createStreamIfNotExists(ctx context.Context, api firehoseiface.FirehoseAPI,
 		return nil
 	}
 
-	if !strings.HasSuffix(tableName, "/") {
-		tableName += "/"
-	}
+	prefix := arn.TableName + "/"
 
 	log.Printf("creating delivery stream, %v, with bucket, %v\n", streamName, bucket)
-	bucketARN := fmt.Sprintf("arn:aws:s3:::%v", bucket)
+	bucketARN := fmt.Sprintf("arn:%v:s3:::%v", arn.Partition, bucket)
 	createInput := firehose.CreateDeliveryStreamInput{
 		DeliveryStreamName: aws.String(streamName),
 		DeliveryStreamType: aws.String(firehose.DeliveryStreamTypeDirectPut),
@@ -95,7 +94,7 @@ func createStreamIfNotExists(ctx context.Context, api firehoseiface.FirehoseAPI,
 					AWSKMSKeyARN: aws.String(keyARN),
 				},
 			},
-			Prefix:  aws.String(tableName),
+			Prefix:  aws.String(prefix),
 			RoleARN: aws.String(roleARN),
 		},
 		Tags: []*firehose.Tag{
@@ -115,7 +114,7 @@ func createStreamIfNotExists(ctx context.Context, api firehoseiface.FirehoseAPI,
 	return nil
 }
 
-func makeFirehoseProducer(api firehoseiface.FirehoseAPI, tableName, streamName, bucket, roleARN, keyARN string) Producer {
+func makeFirehoseProducer(api firehoseiface.FirehoseAPI, arn dynamodbstore.ARN, streamName, bucket, roleARN, keyARN string) Producer {
 	var (
 		mutex      = &sync.Mutex{}
 		allStreams = map[string]struct{}{}
@@ -127,7 +126,7 @@ func makeFirehoseProducer(api firehoseiface.FirehoseAPI, tableName, streamName,
 		mutex.Unlock()
 
 		if !ok {
-			if err := createStreamIfNotExists(ctx, api, tableName, streamName, bucket, roleARN, keyARN); err != nil {
+			if err := createStreamIfNotExists(ctx, api, arn, streamName, bucket, roleARN, keyARN); err != nil {
 				return err
 			}
 
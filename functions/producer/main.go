@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"regexp"
 	"sync"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -21,28 +20,35 @@ import (
 	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/functions/producer/lib"
 )
 
-var reStreamSuffix = regexp.MustCompile(`/stream/[^/]+$`)
-
 type Handler struct {
 	dynamodb        dynamodbiface.DynamoDBAPI
 	firehoseRoleARN string
+	alert           lib.Alerter
 
 	mutex     sync.Mutex
 	producers map[string]lib.Producer
 }
 
 func (h *Handler) handleRecord(ctx context.Context, record events.DynamoDBEventRecord) error {
-	tableArn := reStreamSuffix.ReplaceAllString(record.EventSourceArn, "")
-
-	attr, ok := record.Change.Keys[dynamodbstore.HashKey]
-	if !ok {
-		return fmt.Errorf("unable to determine hash key for table, %v", tableArn)
+	arn, err := dynamodbstore.ParseARN(record.EventSourceArn)
+	if err != nil {
+		return err
 	}
-	aggregateID := attr.String()
+	tableArn := arn.TableARN()
 
-	records, err := dynamodbstore.Changes(record.Change)
+	// a violation may accompany events appended by the same record
+	changeSet, err := dynamodbstore.ParseEventRecord(record)
+	if v, ok := err.(*dynamodbstore.IntegrityError); ok {
+		err = h.alert(ctx, tableArn, v)
+		if err != nil {
+			return err
+		}
+	}
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to parse stream record for table, %v - %v", tableArn, err)
+	}
+	if len(changeSet.Records) == 0 {
+		return nil
 	}
 
 	h.mutex.Lock()
@@ -57,7 +63,7 @@ func (h *Handler) handleRecord(ctx context.Context, record events.DynamoDBEventR
 		fn = v
 	}
 
-	if err := fn(ctx, aggregateID, records); err != nil {
+	if err := fn(ctx, changeSet.AggregateID, changeSet.Records); err != nil {
 		return err
 	}
 
@@ -88,9 +94,15 @@ func main() {
 	h := &Handler{
 		dynamodb:        dynamodb.New(s),
 		firehoseRoleARN: firehoseRoleARN,
+		alert:           lib.LogAlerter,
 		producers:       map[string]lib.Producer{},
 	}
 
+	// ALERT_TOPIC_ARN optionally receives records that modify or remove committed events
+	if alertTopicARN := os.Getenv("ALERT_TOPIC_ARN"); alertTopicARN != "" {
+		h.alert = lib.NewSNSAlerter(sns.New(s), alertTopicARN)
+	}
+
 	lib.Register(lib.NewFirehoseFactory(firehose.New(s), firehoseRoleARN, kmsARN))
 	lib.Register(lib.NewSNSFactory(sns.New(s)))
 	lib.Register(lib.NewSQSFactory(sqs.New(s)))
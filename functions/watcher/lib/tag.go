@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -13,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/awstag"
+	"github.com/eventsource-ecosystem/eventsource-store-dynamodb/dynamodbstore"
 )
 
 func HandleTag(ctx context.Context, dynamodbAPI dynamodbiface.DynamoDBAPI, lambdaAPI lambdaiface.LambdaAPI, req TagRequest, functionName string) error {
@@ -21,7 +21,11 @@ func HandleTag(ctx context.Context, dynamodbAPI dynamodbiface.DynamoDBAPI, lambd
 			continue
 		}
 
-		tableName := extractTableName(req.ResourceArn)
+		tableName, err := dynamodbstore.TableName(req.ResourceArn)
+		if err != nil {
+			return err
+		}
+
 		streamARN, err := lookupStreamARN(ctx, dynamodbAPI, tableName)
 		if err != nil {
 			return err
@@ -40,11 +44,6 @@ func HandleTag(ctx context.Context, dynamodbAPI dynamodbiface.DynamoDBAPI, lambd
 	return nil
 }
 
-func extractTableName(resourceArn string) string {
-	segments := strings.Split(resourceArn, "/")
-	return segments[len(segments)-1]
-}
-
 func lookupStreamARN(ctx context.Context, api dynamodbiface.DynamoDBAPI, tableName string) (*string, error) {
 	output, err := api.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
 	if err != nil {
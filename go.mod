@@ -6,5 +6,6 @@ require (
 	github.com/aws/aws-lambda-go v1.11.1
 	github.com/aws/aws-sdk-go v1.20.1
 	github.com/eventsource-ecosystem/eventsource v0.5.2
+	github.com/golang/protobuf v1.3.2
 	golang.org/x/net v0.0.0-20190613194153-d28f0bde5980 // indirect
 )